@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/breaking"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"gitlab.com/tozd/go/errors"
+)
+
+// checkBreaking runs the configured breaking rules on the dependencies the plan moves to a
+// different commit, against the files vendored from their previous commit. It does nothing when
+// no rules are configured or no dependency moves.
+func (w *workspace) checkBreaking(ctx context.Context, cfg *config.Config, plan *deps.Plan) ([]*breaking.Violation, error) {
+	if len(cfg.BreakingRules) == 0 {
+		return nil, nil
+	}
+
+	moved := false
+	modules := make([]*breaking.Module, 0, len(plan.Results))
+	for i, result := range plan.Results {
+		previous, current := plan.Files(i)
+		if len(previous) == 0 {
+			// New dependencies are checked against themselves, only so imports of them resolve
+			previous = current
+		} else if result.CommitMoved() {
+			moved = true
+		}
+		modules = append(modules, &breaking.Module{
+			Repo:     result.Repo,
+			Dir:      filepath.Base(result.Repo),
+			Previous: previous,
+			Current:  current,
+		})
+	}
+	if !moved {
+		return nil, nil
+	}
+
+	zerolog.Ctx(ctx).Info().Strs("rules", cfg.BreakingRules).Msg("checking breaking changes")
+
+	violations, err := breaking.Check(ctx, w.breakingChecker, cfg.BreakingRules, modules)
+	if err != nil {
+		return nil, err
+	}
+
+	return violations, nil
+}
+
+// breakingError describes the breaking changes that stop a sync
+func breakingError(violations []*breaking.Violation) error {
+	lines := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		lines = append(lines, violation.String())
+	}
+	return errors.Errorf("dependency updates have breaking changes, use --allow-breaking to accept them:\n%s", strings.Join(lines, "\n"))
+}
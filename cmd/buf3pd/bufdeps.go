@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/bsr"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"gitlab.com/tozd/go/errors"
+)
+
+// vendoredBufDeps returns the buf.yaml deps that buf3pd also vendors, warning about each one and
+// marking the git commit pins that differ from the commit locked for the dependency
+func (w *workspace) vendoredBufDeps(ctx context.Context, cfg *config.Config, lockDeps []*lock.Dep) ([]config.VendoredBufDep, error) {
+	log := zerolog.Ctx(ctx)
+
+	mode, err := cfg.BSRDepsMode()
+	if err != nil {
+		return nil, err
+	}
+	if mode == config.BSRDepsIgnore {
+		return nil, nil
+	}
+
+	// v1 workspaces may have no buf.yaml at their root
+	if _, err := os.Stat(w.bufYamlFilePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	bufYaml, err := w.configReader.ReadBufYaml(ctx, w.bufYamlFilePath)
+	if err != nil {
+		return nil, errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	vendored := config.VendoredBufDeps(bufYaml, cfg.Deps)
+	for i := range vendored {
+		dep := &vendored[i]
+		if mode == config.BSRDepsWarn {
+			log.Warn().Str("dep", dep.BufDep).Str("repo", dep.Repo).Msg("buf.yaml dep is also vendored by buf3pd, set bsr_deps: remove to drop it")
+		}
+
+		for _, lockDep := range lockDeps {
+			if lockDep.Repo == dep.Repo && lockDep.Path == dep.Path && lockDep.Ref == dep.Ref {
+				dep.Commit = lockDep.Metadata.Commit
+				break
+			}
+		}
+
+		// Only git commit pins can be compared, BSR commit IDs and labels name something else
+		if dep.Commit != "" && bsr.IsGitCommit(dep.Pin) && dep.Pin != dep.Commit {
+			dep.PinMismatch = true
+			log.Warn().Str("dep", dep.BufDep).Str("pin", dep.Pin).Str("commit", dep.Commit).Msg("BSR-pinned commit differs from the buf3pd lock commit")
+		}
+	}
+
+	return vendored, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/lock"
+)
+
+func TestVendoredBufDepsPinMismatch(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "buf.yaml"), []byte(`version: v2
+deps:
+  - buf.build/acme/commit:0123456789abcdef0123456789abcdef01234567
+  - buf.build/acme/bsrcommit:cc916c31859748a68fd229a3c8d7a2e8
+  - buf.build/acme/label:v1.2.0
+`), 0644))
+
+	cfg := &config.Config{Deps: []config.Buf3pdDep{
+		{Type: "git", Repo: "github.com/acme/commit", Ref: "heads/main", BSR: "buf.build/acme/commit"},
+		{Type: "git", Repo: "github.com/acme/bsrcommit", Ref: "heads/main", BSR: "buf.build/acme/bsrcommit"},
+		{Type: "git", Repo: "github.com/acme/label", Ref: "heads/main", BSR: "buf.build/acme/label"},
+	}}
+	locked := "89abcdef0123456789abcdef0123456789abcdef"
+	var lockDeps []*lock.Dep
+	for _, dep := range cfg.Deps {
+		lockDeps = append(lockDeps, &lock.Dep{Repo: dep.Repo, Ref: dep.Ref, Metadata: lock.LockDepMetadata{Commit: locked}})
+	}
+
+	ws := newWorkspaceAt(workDir, "buf.yaml", "")
+	vendored, err := ws.vendoredBufDeps(ctx, cfg, lockDeps)
+	require.NoError(t, err)
+	require.Len(t, vendored, 3)
+
+	// Only git commit pins are compared with the locked commit
+	for _, dep := range vendored {
+		assert.Equal(t, locked, dep.Commit, dep.Module)
+	}
+	assert.True(t, vendored[0].PinMismatch)
+	assert.False(t, vendored[1].PinMismatch, "BSR commit IDs are not git commits")
+	assert.False(t, vendored[2].PinMismatch, "labels are not git commits")
+}
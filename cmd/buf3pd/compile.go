@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/compile"
+	"github.com/walteh/buf3pd/pkg/deps"
+)
+
+// checkCompile compiles the planned files of all dependencies together, logging every problem, so
+// imports dropped by a filter are reported when syncing rather than by buf build
+func (w *workspace) checkCompile(ctx context.Context, plan *deps.Plan) ([]*compile.Problem, error) {
+	modules := make([]*compile.Module, 0, len(plan.Results))
+	for i, result := range plan.Results {
+		_, current := plan.Files(i)
+		modules = append(modules, &compile.Module{
+			Repo:   result.Repo,
+			Filter: plan.Dep(i).Filter,
+			Files:  current,
+		})
+	}
+
+	problems, err := compile.Check(ctx, modules)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, problem := range problems {
+		zerolog.Ctx(ctx).Warn().Str("repo", problem.Repo).Str("file", problem.Path).Str("suspect", problem.Suspect).Strs("filter", problem.Filter).Msg(problem.Message)
+	}
+
+	return problems, nil
+}
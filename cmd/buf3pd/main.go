@@ -2,87 +2,84 @@ package main
 
 import (
 	"context"
-	"flag"
+	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog"
-	"github.com/walteh/buf3pd/pkg/config"
-	"github.com/walteh/buf3pd/pkg/deps"
-	"github.com/walteh/buf3pd/pkg/file"
-	"github.com/walteh/buf3pd/pkg/git"
-	"github.com/walteh/buf3pd/pkg/lock"
 	"gitlab.com/tozd/go/errors"
 )
 
 // Version will be set during build
 var Version = "dev"
 
+// command is a buf3pd subcommand
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+// commands lists the available subcommands; the first one is the default
+var commands = []*command{
+	{name: "sync", usage: "Fetch dependencies, write the lock file and update buf.yaml", run: runSync},
+	{name: "verify", usage: "Check vendored files against the lock file", run: runVerify},
+	{name: "notice", usage: "Write the license notices of the vendored dependencies", run: runNotice},
+	{name: "sbom", usage: "Write an SBOM of the vendored dependencies", run: runSBOM},
+	{name: "migrate", usage: "Replace BSR deps with vendored git dependencies", run: runMigrate},
+	{name: "schema", usage: "Print the JSON Schema of the buf3pd config file", run: runSchema},
+}
+
+// exitCodeError is returned by commands that need a specific non-zero exit status
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func main() {
 	ctx := context.Background()
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout}).With().Timestamp().Logger()
+	// Logs go to stderr so stdout only carries command output
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
 	ctx = logger.WithContext(ctx)
 
 	log := zerolog.Ctx(ctx)
 	log.Info().Str("version", Version).Msg("starting buf3pd")
 
-	// Parse command line flags
-	var (
-		bufYamlPath = flag.String("config", "buf.yaml", "Path to buf.yaml file")
-		workDir     = flag.String("workdir", ".", "Working directory")
-		skipModules = flag.Bool("skip-modules", false, "Skip updating modules in buf.yaml")
-	)
-	flag.Parse()
-
-	// Ensure workDir is absolute
-	absWorkDir, err := filepath.Abs(*workDir)
+	cmd, args, err := findCommand(os.Args[1:])
 	if err != nil {
-		log.Fatal().Err(errors.Errorf("resolving absolute path for workdir: %w", err)).Msg("failed to start")
+		log.Fatal().Err(err).Msg("failed to start")
 	}
 
-	// Initialize managers
-	configReader := config.NewFileReader()
-	fileManager := file.NewManager()
-	gitManager := git.NewManager()
-	lockManager := lock.NewFileManager()
-	dependencyManager := deps.NewDependencyManager(fileManager, gitManager, lockManager)
-
-	// Read config
-	bufYamlFilePath := filepath.Join(absWorkDir, *bufYamlPath)
-	cfg, err := configReader.ReadConfig(ctx, absWorkDir, bufYamlFilePath)
-	if err != nil {
-		log.Fatal().Err(errors.Errorf("reading buf3pd config: %w", err)).Msg("failed to read buf3pd config")
-	}
-
-	// Read lock file
-	lockFile, err := lockManager.ReadLockFile(filepath.Join(absWorkDir, "buf3pd.lock"))
-	if err != nil {
-		log.Fatal().Err(errors.Errorf("reading lock file: %w", err)).Msg("failed to read lock file")
+	if err := cmd.run(ctx, args); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		log.Fatal().Err(err).Str("command", cmd.name).Msg("buf3pd failed")
 	}
 
-	// Create the output directory if it doesn't exist
-	outputPath := filepath.Join(absWorkDir, cfg.Path)
-	if err := config.ValidatePath(outputPath); err != nil {
-		log.Fatal().Err(errors.Errorf("validating output path: %w", err)).Msg("failed to validate output path")
-	}
+	log.Info().Msg("buf3pd completed successfully")
+}
 
-	// Process dependencies
-	if err := dependencyManager.ProcessDependencies(ctx, cfg, lockFile, outputPath); err != nil {
-		log.Fatal().Err(errors.Errorf("processing dependencies: %w", err)).Msg("failed to process dependencies")
+// findCommand selects the subcommand named by the first argument, defaulting to sync
+func findCommand(args []string) (*command, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, nil
 	}
 
-	// Write lock file
-	if err := lockManager.WriteLockFile(lockFile, filepath.Join(absWorkDir, "buf3pd.lock")); err != nil {
-		log.Fatal().Err(errors.Errorf("writing lock file: %w", err)).Msg("failed to write lock file")
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, args[1:], nil
+		}
 	}
 
-	// Update modules in buf.yaml if not skipped
-	if !*skipModules {
-		if err := configReader.EnsureModulesInBufYaml(ctx, bufYamlFilePath, cfg.Path, cfg.Deps); err != nil {
-			log.Fatal().Err(errors.Errorf("updating modules in buf.yaml: %w", err)).Msg("failed to update modules in buf.yaml")
-		}
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
 	}
 
-	log.Info().Str("path", filepath.Join(absWorkDir, "buf3pd.lock")).Msg("created lock file")
-	log.Info().Msg("buf3pd completed successfully")
+	return nil, nil, errors.Errorf("unknown command %q, expected one of %s", args[0], strings.Join(names, ", "))
 }
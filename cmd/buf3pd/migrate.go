@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/bsr"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/migrate"
+	"gitlab.com/tozd/go/errors"
+)
+
+// runMigrate replaces the BSR deps of buf.yaml and buf.lock with equivalent git dependencies,
+// writing buf.3pd.yaml and running an initial sync
+func runMigrate(ctx context.Context, args []string) error {
+	log := zerolog.Ctx(ctx)
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	path := fs.String("path", "gen/buf3pd", "Output path written to the new buf3pd config")
+	overridesPath := fs.String("overrides", "", "YAML file mapping BSR modules to source repositories, taking precedence over the known ones")
+	force := fs.Bool("force", false, "Overwrite an existing buf3pd config")
+	noSync := fs.Bool("no-sync", false, "Only write the buf3pd config, without fetching dependencies")
+	fs.Parse(args)
+
+	ws, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	configPath := ws.configFilePath
+	if configPath == "" {
+		existing, err := config.FindConfigFile(ws.workDir)
+		if err != nil {
+			return err
+		}
+		configPath = existing
+		if configPath == "" {
+			configPath = filepath.Join(ws.workDir, config.ConfigFileName)
+		}
+	}
+	if ext := filepath.Ext(configPath); ext != ".yaml" && ext != ".yml" {
+		return errors.Errorf("%s: migrate only writes YAML configs", configPath)
+	}
+	if _, err := os.Stat(configPath); err == nil && !*force {
+		return errors.Errorf("%s already exists, use --force to overwrite it", configPath)
+	}
+
+	bufYaml, err := ws.configReader.ReadBufYaml(ctx, ws.bufYamlFilePath)
+	if err != nil {
+		return errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	bufLock, err := bsr.ReadLock(filepath.Join(filepath.Dir(ws.bufYamlFilePath), "buf.lock"))
+	if err != nil {
+		return err
+	}
+
+	var overrides []bsr.Source
+	if *overridesPath != "" {
+		overrides, err = migrate.ReadOverrides(*overridesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	migration, err := migrate.Migrate(bufYaml.Deps, bufLock, overrides)
+	if err != nil {
+		return errors.Errorf("migrating BSR deps: %w", err)
+	}
+
+	for _, module := range migration.Unmapped {
+		log.Warn().Str("module", module).Msg("no source repository known for BSR module, add it to --overrides to migrate it")
+	}
+
+	for _, module := range migration.Unpinned {
+		log.Warn().Str("module", module).Msg("BSR module pin cannot be carried over, migrated to a floating git ref")
+	}
+
+	if len(migration.Deps) == 0 {
+		return errors.New("no BSR deps to migrate")
+	}
+
+	// Migrated modules are removed from buf.yaml by the sync
+	cfg := &config.Config{
+		Path:    *path,
+		Deps:    migration.Deps,
+		BSRDeps: config.BSRDepsRemove,
+	}
+	if err := config.WriteConfig(configPath, cfg); err != nil {
+		return err
+	}
+	log.Info().Str("path", configPath).Int("deps", len(cfg.Deps)).Msg("wrote buf3pd config")
+
+	out := &migrateOutput{Migration: migration, Config: configPath, Results: []*deps.Result{}}
+	if !*noSync {
+		cfg, lockFile, err := ws.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		synced, err := ws.sync(ctx, cfg, lockFile, syncOptions{})
+		if err != nil {
+			return err
+		}
+		out.Results = synced.Deps
+	}
+
+	return writeOutput(os.Stdout, *flags.output, out)
+}
+
+// migrateOutput is the result of a migration
+type migrateOutput struct {
+	*migrate.Migration
+	// Config is the path of the written buf3pd config
+	Config string `json:"config"`
+	// Results are the results of the initial sync, empty with --no-sync
+	Results []*deps.Result `json:"results"`
+}
+
+// WriteText writes the migration in a human-readable form
+func (o *migrateOutput) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Wrote %s:\n", o.Config)
+	for _, dep := range o.Deps {
+		fmt.Fprintf(w, "  %s -> %s (%s@%s)\n", dep.BSR, dep.Repo, dep.Path, dep.Ref)
+	}
+
+	if len(o.Unpinned) > 0 {
+		fmt.Fprintln(w, "Unpinned, the BSR pin is not a git commit:")
+		for _, module := range o.Unpinned {
+			fmt.Fprintf(w, "  %s\n", module)
+		}
+	}
+
+	if len(o.Unmapped) > 0 {
+		fmt.Fprintln(w, "Not migrated, no source repository known:")
+		for _, module := range o.Unmapped {
+			fmt.Fprintf(w, "  %s\n", module)
+		}
+	}
+
+	return nil
+}
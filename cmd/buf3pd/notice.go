@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/walteh/buf3pd/pkg/license"
+	"gitlab.com/tozd/go/errors"
+)
+
+// runNotice writes the aggregated license notices of the locked dependencies
+func runNotice(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("notice", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	outPath := fs.String("out", license.NoticeFileName, "File to write the notices to, relative to --workdir, or - for stdout")
+	fs.Parse(args)
+
+	ws, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	cfg, lockFile, err := ws.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := &noticeOutput{Deps: []*noticeDep{}}
+	notices := make([]*license.Notice, 0, len(lockFile.Deps))
+	for _, dep := range lockFile.Deps {
+		notice := &license.Notice{
+			Repo:    dep.Repo,
+			Path:    dep.Path,
+			Ref:     dep.Ref,
+			Commit:  dep.Metadata.Commit,
+			License: dep.Metadata.License,
+		}
+
+		if dep.Metadata.LicenseFile != "" {
+			path := filepath.Join(ws.outputPath(cfg), filepath.Base(dep.Repo), dep.Metadata.LicenseFile)
+			text, err := ws.fileManager.ReadFile(path)
+			if err != nil {
+				return errors.Errorf("reading license of %s, run buf3pd sync to restore it: %w", dep.Repo, err)
+			}
+			notice.Text = string(text)
+		}
+
+		notices = append(notices, notice)
+		out.Deps = append(out.Deps, &noticeDep{Repo: dep.Repo, License: dep.Metadata.License})
+	}
+
+	if *outPath == "-" {
+		return license.WriteNotices(os.Stdout, notices)
+	}
+
+	var buf bytes.Buffer
+	if err := license.WriteNotices(&buf, notices); err != nil {
+		return err
+	}
+
+	out.Path = *outPath
+	if !filepath.IsAbs(out.Path) {
+		out.Path = filepath.Join(ws.workDir, out.Path)
+	}
+	if err := ws.fileManager.WriteFile(out.Path, buf.Bytes()); err != nil {
+		return errors.Errorf("writing notices: %w", err)
+	}
+
+	return writeOutput(os.Stdout, *flags.output, out)
+}
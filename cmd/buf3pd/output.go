@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/breaking"
+	"github.com/walteh/buf3pd/pkg/compile"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/policy"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Supported values for the --output flag
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// exitChangesPending is the exit code used by --dry-run when applying the plan would change something
+const exitChangesPending = 2
+
+// textWriter is implemented by command results that have a human-readable form
+type textWriter interface {
+	WriteText(w io.Writer) error
+}
+
+// syncOutput is the machine-readable result of a sync
+type syncOutput struct {
+	Deps []*deps.Result `json:"deps"`
+	// Compile are the problems found compiling the synced files of all dependencies together
+	Compile []*compile.Problem `json:"compile,omitempty"`
+
+	// plan is the applied plan
+	plan *deps.Plan
+}
+
+// planOutput is the result of a dry run
+type planOutput struct {
+	*deps.Plan
+	Modules []config.ModuleChange `json:"modules"`
+	// BufDeps are the buf.yaml deps also vendored by buf3pd
+	BufDeps []config.VendoredBufDep `json:"buf_deps"`
+	// RemoveBufDeps is set when BufDeps would be removed from buf.yaml
+	RemoveBufDeps bool `json:"remove_buf_deps"`
+	// Breaking are the breaking changes of dependencies moving to a different commit
+	Breaking []*breaking.Violation `json:"breaking,omitempty"`
+	// Compile are the problems found compiling the planned files of all dependencies together
+	Compile []*compile.Problem `json:"compile,omitempty"`
+	// Policy are the policy violations of the planned dependencies, which would fail a sync
+	Policy  []*policy.Violation `json:"policy,omitempty"`
+	Pending bool                `json:"pending"`
+}
+
+// newPlanOutput creates a planOutput for a dependency plan and the buf.yaml changes it would make
+func newPlanOutput(plan *deps.Plan, modules []config.ModuleChange, bufDeps []config.VendoredBufDep, removeBufDeps bool) *planOutput {
+	if modules == nil {
+		modules = []config.ModuleChange{}
+	}
+	if bufDeps == nil {
+		bufDeps = []config.VendoredBufDep{}
+	}
+	return &planOutput{
+		Plan:          plan,
+		Modules:       modules,
+		BufDeps:       bufDeps,
+		RemoveBufDeps: removeBufDeps,
+		Pending:       plan.HasChanges() || len(modules) > 0 || (removeBufDeps && len(bufDeps) > 0),
+	}
+}
+
+// WriteText writes the plan in a human-readable form
+func (o *planOutput) WriteText(w io.Writer) error {
+	if !o.Pending {
+		fmt.Fprintln(w, "No changes. Vendored dependencies are up to date.")
+		o.writeChecks(w)
+		return nil
+	}
+
+	fmt.Fprintln(w, "Dependencies:")
+	for _, result := range o.Results {
+		action := "up to date"
+		if result.Origin == deps.OriginRemote {
+			action = "fetch from remote"
+		}
+		fmt.Fprintf(w, "  %s (%s@%s): %s\n", result.Repo, result.Path, result.Ref, action)
+		if result.CommitMoved() {
+			fmt.Fprintf(w, "    commit %s -> %s\n", result.PreviousCommit, result.Commit)
+		}
+		for _, path := range result.Added {
+			fmt.Fprintf(w, "    + %s\n", path)
+		}
+		for _, path := range result.Removed {
+			fmt.Fprintf(w, "    - %s\n", path)
+		}
+		for _, path := range result.Changed {
+			fmt.Fprintf(w, "    ~ %s\n", path)
+		}
+	}
+
+	for _, dir := range o.Pruned {
+		fmt.Fprintf(w, "  %s: prune\n", dir)
+	}
+
+	if len(o.Collisions) > 0 {
+		fmt.Fprintln(w, "Collisions:")
+		for _, collision := range o.Collisions {
+			fmt.Fprintf(w, "  %s: keep %s (%s of %s)\n", collision.Path, collision.Kept, collision.Resolution, strings.Join(collision.Repos, ", "))
+		}
+	}
+
+	if len(o.LockChanges) > 0 {
+		fmt.Fprintln(w, "buf3pd.lock:")
+		for _, change := range o.LockChanges {
+			fmt.Fprintf(w, "  %s %s (%s@%s)\n", change.Action, change.Repo, change.Path, change.Ref)
+		}
+	}
+
+	if len(o.Modules) > 0 {
+		fmt.Fprintln(w, "buf.yaml modules:")
+		for _, change := range o.Modules {
+			fmt.Fprintf(w, "  %s %s (%s)\n", change.Action, change.Module.Name, change.Module.Path)
+		}
+	}
+
+	if len(o.Breaking) > 0 {
+		fmt.Fprintln(w, "Breaking changes:")
+		for _, violation := range o.Breaking {
+			fmt.Fprintf(w, "  %s\n", violation)
+		}
+	}
+
+	o.writeChecks(w)
+
+	if len(o.BufDeps) > 0 {
+		action := "vendored"
+		if o.RemoveBufDeps {
+			action = "remove"
+		}
+		fmt.Fprintln(w, "buf.yaml deps:")
+		for _, dep := range o.BufDeps {
+			fmt.Fprintf(w, "  %s %s (%s)\n", action, dep.BufDep, dep.Repo)
+			if dep.PinMismatch {
+				fmt.Fprintf(w, "    pinned to %s, locked at %s\n", dep.Pin, dep.Commit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeChecks writes the policy violations and compile problems, which apply to the vendored files
+// even when nothing changes
+func (o *planOutput) writeChecks(w io.Writer) {
+	if len(o.Policy) > 0 {
+		fmt.Fprintln(w, "Policy violations:")
+		for _, violation := range o.Policy {
+			fmt.Fprintf(w, "  %s\n", violation)
+		}
+	}
+
+	if len(o.Compile) > 0 {
+		fmt.Fprintln(w, "Compile problems:")
+		for _, problem := range o.Compile {
+			fmt.Fprintf(w, "  %s\n", problem)
+		}
+	}
+}
+
+// projectOutput is the result of syncing, or planning the sync of, one project of sync --all
+type projectOutput struct {
+	Dir     string             `json:"dir"`
+	Deps    []*deps.Result     `json:"deps,omitempty"`
+	Compile []*compile.Problem `json:"compile,omitempty"`
+	Plan    *planOutput        `json:"plan,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// syncAllOutput is the aggregate result of sync --all
+type syncAllOutput struct {
+	Projects []*projectOutput `json:"projects"`
+	// Fetches counts the dependencies fetched from their sources, Reused those shared between projects
+	Fetches int  `json:"fetches"`
+	Reused  int  `json:"reused"`
+	Pending bool `json:"pending,omitempty"`
+}
+
+// WriteText writes the results of every project followed by a summary
+func (o *syncAllOutput) WriteText(w io.Writer) error {
+	failed := 0
+	for _, project := range o.Projects {
+		fmt.Fprintf(w, "%s:\n", project.Dir)
+		switch {
+		case project.Error != "":
+			failed++
+			fmt.Fprintf(w, "  failed: %s\n", project.Error)
+		case project.Plan != nil:
+			var buf bytes.Buffer
+			if err := project.Plan.WriteText(&buf); err != nil {
+				return err
+			}
+			for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+		default:
+			for _, result := range project.Deps {
+				action := "up to date"
+				if result.Origin == deps.OriginRemote {
+					action = "synced"
+				}
+				fmt.Fprintf(w, "  %s (%s@%s): %s\n", result.Repo, result.Path, result.Ref, action)
+			}
+			for _, problem := range project.Compile {
+				fmt.Fprintf(w, "  compile problem: %s\n", problem)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d projects, %d failed, %d fetches, %d reused\n", len(o.Projects), failed, o.Fetches, o.Reused)
+	return err
+}
+
+// verifyOutput is the result of verifying vendored files against the lock file
+type verifyOutput struct {
+	Deps []*deps.Verification `json:"deps"`
+	OK   bool                 `json:"ok"`
+}
+
+// newVerifyOutput creates a verifyOutput, which is only OK when every dependency verified
+func newVerifyOutput(verifications []*deps.Verification) *verifyOutput {
+	ok := true
+	for _, verification := range verifications {
+		ok = ok && verification.OK
+	}
+	return &verifyOutput{
+		Deps: verifications,
+		OK:   ok,
+	}
+}
+
+// WriteText writes the verification results in a human-readable form
+func (o *verifyOutput) WriteText(w io.Writer) error {
+	for _, verification := range o.Deps {
+		switch {
+		case !verification.Locked:
+			fmt.Fprintf(w, "%s (%s@%s): not locked\n", verification.Repo, verification.Path, verification.Ref)
+			continue
+		case verification.OK:
+			fmt.Fprintf(w, "%s (%s@%s): ok\n", verification.Repo, verification.Path, verification.Ref)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s (%s@%s): digest mismatch, expected %s got %s\n", verification.Repo, verification.Path, verification.Ref, verification.ExpectedDigest, verification.ActualDigest)
+		for _, path := range verification.Modified {
+			fmt.Fprintf(w, "  modified:   %s\n", path)
+		}
+		for _, path := range verification.Missing {
+			fmt.Fprintf(w, "  missing:    %s\n", path)
+		}
+		for _, path := range verification.Unexpected {
+			fmt.Fprintf(w, "  unexpected: %s\n", path)
+		}
+	}
+
+	return nil
+}
+
+// validateOutputFormat ensures the --output flag holds a supported format
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return errors.Errorf("unsupported output format %q, expected %q or %q", format, outputText, outputJSON)
+	}
+}
+
+// writeOutput writes a command result to w, as JSON or in its human-readable form if it has one
+func writeOutput(w io.Writer, format string, v any) error {
+	if format != outputJSON {
+		tw, ok := v.(textWriter)
+		if !ok {
+			return nil
+		}
+		if err := tw.WriteText(w); err != nil {
+			return errors.Errorf("writing output: %w", err)
+		}
+		return nil
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return errors.Errorf("encoding output: %w", err)
+	}
+
+	return nil
+}
+
+// noticeOutput is the result of writing the license notices
+type noticeOutput struct {
+	Path string       `json:"path"`
+	Deps []*noticeDep `json:"deps"`
+}
+
+// noticeDep is the license recorded for a dependency
+type noticeDep struct {
+	Repo    string `json:"repo"`
+	License string `json:"license"`
+}
+
+// WriteText writes the licenses of the dependencies and where the notices were written
+func (o *noticeOutput) WriteText(w io.Writer) error {
+	for _, dep := range o.Deps {
+		license := dep.License
+		if license == "" {
+			license = "unknown"
+		}
+		fmt.Fprintf(w, "%s: %s\n", dep.Repo, license)
+	}
+	_, err := fmt.Fprintf(w, "Wrote %s\n", o.Path)
+	return err
+}
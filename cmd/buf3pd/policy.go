@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/policy"
+	"gitlab.com/tozd/go/errors"
+)
+
+// checkPolicies checks the planned files of every dependency with a policy against it, using the
+// license file read when the dependency was fetched or the copy vendored next to its files
+func (w *workspace) checkPolicies(ctx context.Context, plan *deps.Plan) ([]*policy.Violation, error) {
+	violations := []*policy.Violation{}
+	for i, result := range plan.Results {
+		dep := plan.Dep(i)
+		if dep.Policy == nil {
+			continue
+		}
+
+		licenseFile, recorded := plan.License(i)
+		if policy.NeedsLicense(dep.Policy) && recorded == "" {
+			return nil, errors.Errorf("checking policy of %s: %s sources cannot read licenses", result.Repo, dep.Type)
+		}
+
+		_, current := plan.Files(i)
+		depViolations, err := policy.Check(dep, current, licenseFile)
+		if err != nil {
+			return nil, errors.Errorf("checking policy of %s: %w", result.Repo, err)
+		}
+		violations = append(violations, depViolations...)
+	}
+
+	return violations, nil
+}
+
+// policyError describes the policy violations that stop a sync
+func policyError(violations []*policy.Violation) error {
+	lines := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		lines = append(lines, violation.String())
+	}
+	return errors.Errorf("dependencies violate their policy:\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/report"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+)
+
+// changeReport reports the upstream changes of the planned dependencies that move to a different
+// commit. Failing to list a dependency's commits is noted in the report rather than failing it.
+func (w *workspace) changeReport(ctx context.Context, plan *deps.Plan) (*report.Report, error) {
+	log := zerolog.Ctx(ctx)
+	out := &report.Report{Deps: []*report.Dep{}}
+
+	for i, result := range plan.Results {
+		if !result.CommitMoved() {
+			continue
+		}
+
+		previous, current := plan.Files(i)
+		dep, err := report.NewDep(result, previous, current)
+		if err != nil {
+			return nil, err
+		}
+
+		src, ok := w.sources.Lookup(result.Type)
+		lister, listable := source.As[source.ChangeLister](src)
+		switch {
+		case !ok || !listable:
+			dep.ChangesError = "not supported by " + result.Type + " sources"
+		default:
+			cfgDep := config.Buf3pdDep{Type: result.Type, Repo: result.Repo, Path: result.Path, Ref: result.Ref}
+			changes, err := lister.Changes(ctx, cfgDep, &source.Pin{Version: result.PreviousCommit}, &source.Pin{Version: result.Commit})
+			if err != nil {
+				log.Warn().Err(err).Str("repo", result.Repo).Msg("listing upstream changes failed")
+				dep.ChangesError = err.Error()
+			} else {
+				dep.Changes = changes
+			}
+		}
+
+		out.Deps = append(out.Deps, dep)
+	}
+
+	return out, nil
+}
+
+// writeReport writes the markdown change report of a plan to path
+func (w *workspace) writeReport(ctx context.Context, plan *deps.Plan, path string) error {
+	out, err := w.changeReport(ctx, plan)
+	if err != nil {
+		return errors.Errorf("building change report: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Errorf("creating change report: %w", err)
+	}
+	defer f.Close()
+
+	if err := out.WriteMarkdown(f); err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("path", path).Int("deps", len(out.Deps)).Msg("wrote change report")
+
+	return f.Close()
+}
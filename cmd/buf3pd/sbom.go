@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walteh/buf3pd/pkg/sbom"
+	"gitlab.com/tozd/go/errors"
+)
+
+// runSBOM writes an SBOM of the locked dependencies
+func runSBOM(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sbom", flag.ExitOnError)
+	// The SBOM format is selected with --format, so there is no --output flag
+	flags := registerPathFlags(fs)
+	format := fs.String("format", sbom.FormatCycloneDX, "SBOM format ("+strings.Join(sbom.Formats, " or ")+")")
+	outPath := fs.String("out", "-", "File to write the SBOM to, relative to --workdir, or - for stdout")
+	// The name defaults to the lock file the SBOM describes, so it does not depend on where the project is checked out
+	name := fs.String("name", "buf3pd.lock", "Name of the SBOM document")
+	fs.Parse(args)
+
+	ws, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	// The SBOM only describes the lock file, so the config is not needed
+	lockFile, err := ws.lockManager.ReadLockFile(ws.lockFilePath)
+	if err != nil {
+		return errors.Errorf("reading lock file: %w", err)
+	}
+
+	created, err := sbomCreated()
+	if err != nil {
+		return err
+	}
+
+	doc := sbom.NewDocument(*name, lockFile)
+	doc.Tool = "buf3pd"
+	doc.ToolVersion = Version
+	doc.Created = created
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, *format); err != nil {
+		return err
+	}
+
+	if *outPath == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	path := *outPath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ws.workDir, path)
+	}
+	if err := ws.fileManager.WriteFile(path, buf.Bytes()); err != nil {
+		return errors.Errorf("writing SBOM: %w", err)
+	}
+
+	return nil
+}
+
+// sbomCreated returns the creation time recorded by SBOM formats that require one. It is taken from
+// SOURCE_DATE_EPOCH, as for reproducible builds, and is the Unix epoch otherwise, so the SBOM only
+// changes with the lock file.
+func sbomCreated() (string, error) {
+	created := time.Unix(0, 0)
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return "", errors.Errorf("parsing SOURCE_DATE_EPOCH: %w", err)
+		}
+		created = time.Unix(seconds, 0)
+	}
+	return created.UTC().Format(time.RFC3339), nil
+}
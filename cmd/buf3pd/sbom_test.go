@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSBOMDoesNotDependOnCheckoutDirectory(t *testing.T) {
+	lockContent := []byte("version: v3\ndeps: []\n")
+
+	sbomIn := func(dir string, args ...string) string {
+		workDir := filepath.Join(t.TempDir(), dir)
+		require.NoError(t, os.MkdirAll(workDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "buf3pd.lock"), lockContent, 0644))
+
+		args = append([]string{"--workdir", workDir, "--format", "spdx", "--out", "sbom.json"}, args...)
+		require.NoError(t, runSBOM(context.Background(), args))
+
+		content, err := os.ReadFile(filepath.Join(workDir, "sbom.json"))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	// Checkouts of the same lock file in differently named directories produce the same SBOM
+	assert.Equal(t, sbomIn("checkout"), sbomIn("other-checkout"))
+
+	assert.Contains(t, sbomIn("checkout"), `"name": "buf3pd.lock"`)
+	assert.Contains(t, sbomIn("checkout", "--name", "acme-protos"), `"name": "acme-protos"`)
+}
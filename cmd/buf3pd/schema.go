@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/walteh/buf3pd/pkg/config"
+)
+
+// runSchema prints the JSON Schema of the buf3pd config file
+func runSchema(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Parse(args)
+
+	return writeOutput(os.Stdout, outputJSON, config.JSONSchema())
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+)
+
+// syncOptions holds the sync flags that change how a workspace is synced
+type syncOptions struct {
+	skipModules   bool
+	allowBreaking bool
+}
+
+// runSync fetches dependencies, writes the lock file and updates buf.yaml
+func runSync(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	skipModules := fs.Bool("skip-modules", false, "Skip updating modules in buf.yaml")
+	dryRun := fs.Bool("dry-run", false, "Print what would change without writing anything, exiting 2 if changes are pending")
+	all := fs.Bool("all", false, "Sync every project with a buf3pd config below --workdir, fetching identical dependencies once")
+	reportPath := fs.String("report", "", "Write a markdown report of the upstream changes of dependencies moving to a different commit to this file")
+	allowBreaking := fs.Bool("allow-breaking", false, "Accept dependencies moving to a commit with breaking changes")
+	fs.Parse(args)
+
+	opts := syncOptions{skipModules: *skipModules, allowBreaking: *allowBreaking}
+
+	if *all {
+		if *reportPath != "" {
+			return errors.New("--report cannot be used with --all")
+		}
+		return runSyncAll(ctx, flags, opts, *dryRun)
+	}
+
+	ws, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		out, err := ws.plan(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if *reportPath != "" {
+			if err := ws.writeReport(ctx, out.Plan, *reportPath); err != nil {
+				return err
+			}
+		}
+		if err := writeOutput(os.Stdout, *flags.output, out); err != nil {
+			return err
+		}
+
+		// Policy violations fail a dry run like the sync they would stop
+		if len(out.Policy) > 0 {
+			return policyError(out.Policy)
+		}
+		if out.Pending {
+			return &exitCodeError{code: exitChangesPending}
+		}
+		return nil
+	}
+
+	out, err := ws.syncProject(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if *reportPath != "" {
+		if err := ws.writeReport(ctx, out.plan, *reportPath); err != nil {
+			return err
+		}
+	}
+
+	return writeOutput(os.Stdout, *flags.output, out)
+}
+
+// runSyncAll syncs every project with a standalone buf3pd config below the working directory. The
+// projects share one source cache, so a dependency they declare identically is fetched once.
+func runSyncAll(ctx context.Context, flags *commonFlags, opts syncOptions, dryRun bool) error {
+	log := zerolog.Ctx(ctx)
+
+	if *flags.configPath != "" {
+		return errors.New("--3pd-config cannot be used with --all")
+	}
+
+	root, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	configPaths, err := config.FindConfigFiles(root.workDir)
+	if err != nil {
+		return err
+	}
+	if len(configPaths) == 0 {
+		return errors.Errorf("no buf3pd configs found below %s", root.workDir)
+	}
+
+	cache := source.NewCache()
+	workspaces := make([]*workspace, 0, len(configPaths))
+	for _, configPath := range configPaths {
+		ws := newWorkspaceAt(filepath.Dir(configPath), *flags.bufYamlPath, configPath)
+		ws.configReason = "found by sync --all"
+		ws.sources.UseCache(cache)
+		workspaces = append(workspaces, ws)
+	}
+
+	// Plan every project first, so projects sharing a vendor directory can update a shared dependency together
+	moving := planMoving(ctx, workspaces)
+
+	out := &syncAllOutput{Projects: []*projectOutput{}}
+	failed := 0
+	for _, ws := range workspaces {
+		ws.moving = moving
+
+		dir, err := filepath.Rel(root.workDir, ws.workDir)
+		if err != nil {
+			return errors.Errorf("resolving project directory: %w", err)
+		}
+		project := &projectOutput{Dir: dir}
+
+		if dryRun {
+			project.Plan, err = ws.plan(ctx, opts)
+			if err == nil && len(project.Plan.Policy) > 0 {
+				err = policyError(project.Plan.Policy)
+			}
+			out.Pending = out.Pending || (err == nil && project.Plan.Pending)
+		} else {
+			var synced *syncOutput
+			synced, err = ws.syncProject(ctx, opts)
+			if err == nil {
+				project.Deps = synced.Deps
+				project.Compile = synced.Compile
+			}
+		}
+		if err != nil {
+			// A failing project does not stop the others from syncing
+			log.Error().Err(err).Str("dir", dir).Msg("project failed")
+			project.Error = err.Error()
+			failed++
+		}
+
+		out.Projects = append(out.Projects, project)
+	}
+	out.Fetches, out.Reused = cache.Stats()
+
+	if err := writeOutput(os.Stdout, *flags.output, out); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d projects failed", failed, len(out.Projects))
+	}
+	if out.Pending {
+		return &exitCodeError{code: exitChangesPending}
+	}
+	return nil
+}
+
+// planMoving plans the dependencies of every project vendoring into a shared directory, returning
+// the planned lock entries by working directory. Projects failing to plan are left out, and fail
+// again when they are synced.
+func planMoving(ctx context.Context, workspaces []*workspace) map[string][]*lock.Dep {
+	moving := map[string][]*lock.Dep{}
+	for _, ws := range workspaces {
+		cfg, lockFile, err := ws.load(ctx)
+		if err != nil || !cfg.Shared {
+			continue
+		}
+		plan, err := ws.dependencyManager.PlanDependencies(ctx, cfg, lockFile, ws.outputPath(cfg))
+		if err != nil {
+			continue
+		}
+		moving[ws.workDir] = plan.LockDeps
+	}
+	return moving
+}
+
+// plan computes what syncing the workspace would change without writing anything
+func (w *workspace) plan(ctx context.Context, opts syncOptions) (*planOutput, error) {
+	cfg, lockFile, err := w.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := w.dependencyManager.PlanDependencies(ctx, cfg, lockFile, w.outputPath(cfg))
+	if err != nil {
+		return nil, errors.Errorf("planning dependencies: %w", err)
+	}
+
+	// The updated index is only used to find what would be pruned, and is not written
+	if _, err := w.updateIndex(cfg, plan); err != nil {
+		return nil, err
+	}
+
+	violations, err := w.checkBreaking(ctx, cfg, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	problems, err := w.checkCompile(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	policyViolations, err := w.checkPolicies(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []config.ModuleChange
+	if !opts.skipModules {
+		modules, err = w.configReader.PlanModulesInBufYaml(ctx, w.bufYamlFilePath, cfg.Path, cfg.Deps)
+		if err != nil {
+			return nil, errors.Errorf("planning modules in buf.yaml: %w", err)
+		}
+	}
+
+	vendored, err := w.vendoredBufDeps(ctx, cfg, plan.LockDeps)
+	if err != nil {
+		return nil, errors.Errorf("checking buf.yaml deps: %w", err)
+	}
+
+	out := newPlanOutput(plan, modules, vendored, cfg.BSRDeps == config.BSRDepsRemove)
+	out.Breaking = violations
+	out.Compile = problems
+	out.Policy = policyViolations
+
+	return out, nil
+}
+
+// syncProject loads the workspace's config and lock file and syncs it
+func (w *workspace) syncProject(ctx context.Context, opts syncOptions) (*syncOutput, error) {
+	cfg, lockFile, err := w.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := w.sync(ctx, cfg, lockFile, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("path", w.lockFilePath).Msg("created lock file")
+
+	return out, nil
+}
+
+// sync fetches dependencies into the output path, writes the lock file and updates buf.yaml
+func (w *workspace) sync(ctx context.Context, cfg *config.Config, lockFile *lock.File, opts syncOptions) (*syncOutput, error) {
+	// Create the output directory if it doesn't exist
+	if err := config.ValidatePath(w.outputPath(cfg)); err != nil {
+		return nil, errors.Errorf("validating output path: %w", err)
+	}
+
+	// Process dependencies
+	plan, err := w.dependencyManager.PlanDependencies(ctx, cfg, lockFile, w.outputPath(cfg))
+	if err != nil {
+		return nil, errors.Errorf("planning dependencies: %w", err)
+	}
+
+	policyViolations, err := w.checkPolicies(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	if len(policyViolations) > 0 {
+		return nil, policyError(policyViolations)
+	}
+
+	violations, err := w.checkBreaking(ctx, cfg, plan)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		if !opts.allowBreaking {
+			return nil, breakingError(violations)
+		}
+		zerolog.Ctx(ctx).Warn().Int("violations", len(violations)).Msg("accepting breaking changes")
+	}
+
+	// Compile problems are only reported, since buf.yaml deps may still provide missing imports
+	problems, err := w.checkCompile(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := w.updateIndex(cfg, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.dependencyManager.ApplyPlan(ctx, plan, lockFile, w.outputPath(cfg)); err != nil {
+		return nil, errors.Errorf("applying plan: %w", err)
+	}
+
+	// Write lock file
+	if err := w.lockManager.WriteLockFile(lockFile, w.lockFilePath); err != nil {
+		return nil, errors.Errorf("writing lock file: %w", err)
+	}
+
+	if index != nil {
+		if err := lock.WriteIndex(index, w.indexPath(cfg)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Update modules in buf.yaml if not skipped
+	if !opts.skipModules {
+		if err := w.configReader.EnsureModulesInBufYaml(ctx, w.bufYamlFilePath, cfg.Path, cfg.Deps); err != nil {
+			return nil, errors.Errorf("updating modules in buf.yaml: %w", err)
+		}
+	}
+
+	vendored, err := w.vendoredBufDeps(ctx, cfg, lockFile.Deps)
+	if err != nil {
+		return nil, errors.Errorf("checking buf.yaml deps: %w", err)
+	}
+
+	if cfg.BSRDeps == config.BSRDepsRemove && len(vendored) > 0 {
+		refs := make([]string, 0, len(vendored))
+		for _, dep := range vendored {
+			refs = append(refs, dep.BufDep)
+		}
+		if err := w.configReader.RemoveBufYamlDeps(ctx, w.bufYamlFilePath, refs); err != nil {
+			return nil, errors.Errorf("removing vendored deps from buf.yaml: %w", err)
+		}
+	}
+
+	return &syncOutput{Deps: plan.Results, Compile: problems, plan: plan}, nil
+}
+
+// indexPath returns the path of the consumer index of a shared vendor directory
+func (w *workspace) indexPath(cfg *config.Config) string {
+	return filepath.Join(w.outputPath(cfg), lock.IndexFileName)
+}
+
+// updateIndex records the planned dependencies of the workspace in the index of a shared vendor
+// directory, so the plan only prunes directories no other project uses. It returns nil when the
+// vendor directory is not shared.
+func (w *workspace) updateIndex(cfg *config.Config, plan *deps.Plan) (*lock.Index, error) {
+	if !cfg.Shared {
+		return nil, nil
+	}
+
+	index, err := lock.ReadIndex(w.indexPath(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := w.consumer(cfg, w.workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	moving := make(map[string][]*lock.Dep, len(w.moving))
+	for workDir, lockDeps := range w.moving {
+		other, err := w.consumer(cfg, workDir)
+		if err != nil {
+			return nil, err
+		}
+		moving[other] = lockDeps
+	}
+
+	pruned, err := index.Update(consumer, plan.LockDeps, moving)
+	if err != nil {
+		return nil, errors.Errorf("updating %s: %w", lock.IndexFileName, err)
+	}
+	plan.Pruned = pruned
+
+	return index, nil
+}
+
+// consumer returns the name a project's working directory is recorded under in the index of the
+// workspace's shared vendor directory
+func (w *workspace) consumer(cfg *config.Config, workDir string) (string, error) {
+	consumer, err := filepath.Rel(w.outputPath(cfg), workDir)
+	if err != nil {
+		return "", errors.Errorf("resolving consumer: %w", err)
+	}
+	return filepath.ToSlash(consumer), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+)
+
+// staticSource serves the same files for every dependency
+type staticSource struct {
+	files []*file.File
+}
+
+func (s *staticSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*source.Pin, error) {
+	return &source.Pin{Version: "v1"}, nil
+}
+
+func (s *staticSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *source.Pin) ([]*file.File, error) {
+	return s.files, nil
+}
+
+func TestSyncReportsCompileProblems(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "buf.yaml"), []byte("version: v2\n"), 0644))
+
+	ws := newWorkspaceAt(workDir, "buf.yaml", "")
+	ws.sources.Register("static", &staticSource{files: []*file.File{{
+		Path: "acme/v1/service.proto",
+		Content: []byte(`syntax = "proto3";
+
+package acme.v1;
+
+import "google/rpc/status.proto";
+
+message Response {
+  google.rpc.Status status = 1;
+}
+`),
+	}}})
+
+	cfg := &config.Config{
+		Path: "third_party",
+		Deps: []config.Buf3pdDep{{Type: "static", Repo: "github.com/acme/protos", Ref: "main"}},
+	}
+	lockFile := &lock.File{Version: lock.CurrentVersion, Deps: []*lock.Dep{}}
+
+	out, err := ws.sync(ctx, cfg, lockFile, syncOptions{skipModules: true})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(workDir, "third_party", "protos", "acme/v1/service.proto"))
+
+	// A sync that is not a dry run reports the missing import in its output
+	require.Len(t, out.Compile, 1)
+	assert.Equal(t, "google/rpc/status.proto", out.Compile[0].Import)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeOutput(&buf, outputJSON, out))
+	assert.Contains(t, buf.String(), `"import": "google/rpc/status.proto"`)
+}
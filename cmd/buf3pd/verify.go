@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// exitVerifyFailed is the exit code used by verify when vendored files drifted from the lock file
+const exitVerifyFailed = 1
+
+// runVerify checks the vendored files against the lock file without fetching anything
+func runVerify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	fs.Parse(args)
+
+	ws, err := newWorkspace(flags)
+	if err != nil {
+		return err
+	}
+
+	cfg, lockFile, err := ws.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	verifications, err := ws.dependencyManager.VerifyDependencies(ctx, cfg, lockFile, ws.outputPath(cfg))
+	if err != nil {
+		return errors.Errorf("verifying dependencies: %w", err)
+	}
+
+	out := newVerifyOutput(verifications)
+	if err := writeOutput(os.Stdout, *flags.output, out); err != nil {
+		return err
+	}
+
+	if !out.OK {
+		return &exitCodeError{code: exitVerifyFailed}
+	}
+
+	return nil
+}
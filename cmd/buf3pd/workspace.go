@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/breaking"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/git"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+)
+
+// commonFlags holds the flags shared by every command
+type commonFlags struct {
+	bufYamlPath *string
+	configPath  *string
+	workDir     *string
+	output      *string
+}
+
+// registerCommonFlags registers the shared flags on a command's flag set
+func registerCommonFlags(fs *flag.FlagSet) *commonFlags {
+	flags := registerPathFlags(fs)
+	flags.output = fs.String("output", outputText, "Output format (text or json)")
+	return flags
+}
+
+// registerPathFlags registers the shared flags locating the project, for commands with their own
+// output format
+func registerPathFlags(fs *flag.FlagSet) *commonFlags {
+	output := outputText
+	return &commonFlags{
+		bufYamlPath: fs.String("config", "buf.yaml", "Path to buf.yaml file"),
+		configPath:  fs.String("3pd-config", "", "Path to the buf3pd config file, discovered from the working directory if empty"),
+		workDir:     fs.String("workdir", ".", "Working directory"),
+		output:      &output,
+	}
+}
+
+// workspace holds the resolved paths and managers shared by every command
+type workspace struct {
+	workDir         string
+	bufYamlPath     string
+	bufYamlFilePath string
+	configFilePath  string
+	// configReason explains where an explicit configFilePath came from
+	configReason string
+	lockFilePath string
+
+	configReader      *config.FileReader
+	lockManager       *lock.FileManager
+	dependencyManager *deps.DependencyManager
+
+	fileManager     *file.Manager
+	gitManager      *git.Manager
+	sources         *source.Registry
+	breakingChecker breaking.Checker
+
+	// moving maps the working directories of the projects synced in the same run to the lock entries
+	// they are planned to vendor, so shared dependencies can be updated together
+	moving map[string][]*lock.Dep
+}
+
+// newWorkspace resolves the command's paths and initializes the managers
+func newWorkspace(flags *commonFlags) (*workspace, error) {
+	if err := validateOutputFormat(*flags.output); err != nil {
+		return nil, err
+	}
+
+	// Ensure workDir is absolute
+	absWorkDir, err := filepath.Abs(*flags.workDir)
+	if err != nil {
+		return nil, errors.Errorf("resolving absolute path for workdir: %w", err)
+	}
+
+	// An explicit buf3pd config is resolved like --config, relative to the working directory
+	configFilePath := *flags.configPath
+	if configFilePath != "" && !filepath.IsAbs(configFilePath) {
+		configFilePath = filepath.Join(absWorkDir, configFilePath)
+	}
+
+	ws := newWorkspaceAt(absWorkDir, *flags.bufYamlPath, configFilePath)
+	ws.configReason = "given with --3pd-config"
+
+	return ws, nil
+}
+
+// newWorkspaceAt initializes the managers for a workspace in an absolute working directory. An
+// empty configFilePath discovers the buf3pd config from the working directory.
+func newWorkspaceAt(workDir string, bufYamlPath string, configFilePath string) *workspace {
+	// Initialize managers
+	fileManager := file.NewManager()
+	gitManager := git.NewManager()
+	lockManager := lock.NewFileManager()
+
+	// The git source is registered once the config, which may configure mirrors, is read
+	sources := source.NewRegistry()
+
+	return &workspace{
+		workDir:           workDir,
+		bufYamlPath:       bufYamlPath,
+		bufYamlFilePath:   filepath.Join(workDir, bufYamlPath),
+		configFilePath:    configFilePath,
+		lockFilePath:      filepath.Join(workDir, "buf3pd.lock"),
+		configReader:      config.NewFileReader(),
+		lockManager:       lockManager,
+		dependencyManager: deps.NewDependencyManager(fileManager, lockManager, sources),
+		fileManager:       fileManager,
+		gitManager:        gitManager,
+		sources:           sources,
+		breakingChecker:   breaking.NewBufChecker(),
+	}
+}
+
+// load reads the buf3pd config and lock file, and registers the git source with the configured mirrors
+func (w *workspace) load(ctx context.Context) (*config.Config, *lock.File, error) {
+	cfg, err := w.readConfig(ctx)
+	if err != nil {
+		return nil, nil, errors.Errorf("reading buf3pd config: %w", err)
+	}
+
+	mirrors, err := git.MirrorsFromEnv(cfg.Mirrors)
+	if err != nil {
+		return nil, nil, errors.Errorf("reading mirrors: %w", err)
+	}
+
+	// Register the built-in dependency sources
+	w.sources.Register(source.GitType, source.NewGitSource(w.fileManager, git.NewMirrorHandler(w.gitManager, mirrors)))
+
+	lockFile, err := w.lockManager.ReadLockFile(w.lockFilePath)
+	if err != nil {
+		return nil, nil, errors.Errorf("reading lock file: %w", err)
+	}
+
+	return cfg, lockFile, nil
+}
+
+// readConfig reads the buf3pd config given with --3pd-config, or discovers it from the working directory
+func (w *workspace) readConfig(ctx context.Context) (*config.Config, error) {
+	if w.configFilePath != "" {
+		zerolog.Ctx(ctx).Info().Str("path", w.configFilePath).Str("reason", w.configReason).Msg("using buf3pd config")
+		return w.configReader.ReadConfigFile(ctx, w.configFilePath)
+	}
+
+	cfg, err := w.configReader.ReadConfig(ctx, w.workDir, w.bufYamlFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// A config found in a parent directory makes that directory the project, so buf.yaml, the lock
+	// file and the vendored files are resolved next to the config rather than below the working directory
+	if dir := filepath.Dir(cfg.File); cfg.File != w.bufYamlFilePath && dir != w.workDir {
+		zerolog.Ctx(ctx).Info().Str("dir", dir).Msg("using the directory of the buf3pd config as working directory")
+		w.setWorkDir(dir)
+	}
+
+	return cfg, nil
+}
+
+// setWorkDir moves the workspace to another working directory, resolving its paths again
+func (w *workspace) setWorkDir(workDir string) {
+	w.workDir = workDir
+	w.bufYamlFilePath = filepath.Join(workDir, w.bufYamlPath)
+	w.lockFilePath = filepath.Join(workDir, "buf3pd.lock")
+}
+
+// outputPath returns the absolute directory dependencies are vendored into
+func (w *workspace) outputPath(cfg *config.Config) string {
+	return filepath.Join(w.workDir, cfg.Path)
+}
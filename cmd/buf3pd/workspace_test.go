@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFromNestedDirectory(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "proto", "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "buf.3pd.yaml"), []byte(`path: third_party
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: heads/main
+`), 0644))
+
+	// Running from a subdirectory resolves every path next to the config found in the parent
+	ws := newWorkspaceAt(filepath.Join(root, "proto", "nested"), "buf.yaml", "")
+	cfg, err := ws.readConfig(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, root, ws.workDir)
+	assert.Equal(t, filepath.Join(root, "buf.yaml"), ws.bufYamlFilePath)
+	assert.Equal(t, filepath.Join(root, "buf3pd.lock"), ws.lockFilePath)
+	assert.Equal(t, filepath.Join(root, "third_party"), ws.outputPath(cfg))
+}
@@ -0,0 +1,142 @@
+package breaking
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/file"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Violation is a breaking change found in a vendored dependency
+type Violation struct {
+	Repo    string `json:"repo"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: %s:%d:%d: %s (%s)", v.Repo, v.Path, v.Line, v.Column, v.Message, v.Rule)
+}
+
+// Checker runs breaking change rules on a buf workspace against a previous version of it
+type Checker interface {
+	Check(ctx context.Context, current string, against string) ([]*Violation, error)
+}
+
+// Module is a vendored dependency in both its previous and its new version. Its files are rooted
+// at Dir, the directory the dependency is vendored into, as in buf.yaml.
+type Module struct {
+	Repo     string
+	Dir      string
+	Previous []*file.File
+	Current  []*file.File
+}
+
+// Check runs the rules on the new versions of the modules against their previous versions. Both
+// versions are written to temporary buf workspaces holding every module, so imports between
+// vendored dependencies resolve. Violations are attributed to the module of the file they were
+// found in, with paths relative to the module.
+func Check(ctx context.Context, checker Checker, rules []string, modules []*Module) ([]*Violation, error) {
+	tempDir, err := os.MkdirTemp("", "buf3pd-breaking-")
+	if err != nil {
+		return nil, errors.Errorf("creating temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	current := filepath.Join(tempDir, "current")
+	against := filepath.Join(tempDir, "against")
+	for _, tree := range []struct {
+		dir      string
+		previous bool
+	}{{current, false}, {against, true}} {
+		if err := writeWorkspace(tree.dir, rules, modules, tree.previous); err != nil {
+			return nil, err
+		}
+	}
+
+	violations, err := checker.Check(ctx, current, against)
+	if err != nil {
+		return nil, errors.Errorf("checking breaking changes: %w", err)
+	}
+
+	for _, violation := range violations {
+		violation.Repo, violation.Path = attribute(violation.Path, current, modules)
+	}
+
+	return violations, nil
+}
+
+// writeWorkspace writes a buf v2 workspace with a module per dependency, configured with the
+// breaking rules
+func writeWorkspace(dir string, rules []string, modules []*Module, previous bool) error {
+	type moduleEntry struct {
+		Path string `yaml:"path"`
+	}
+	bufYaml := struct {
+		Version  string        `yaml:"version"`
+		Modules  []moduleEntry `yaml:"modules"`
+		Breaking struct {
+			Use []string `yaml:"use"`
+		} `yaml:"breaking"`
+	}{Version: "v2"}
+	bufYaml.Breaking.Use = rules
+
+	fileHandler := file.NewManager()
+	for _, module := range modules {
+		files := module.Current
+		if previous {
+			files = module.Previous
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if err := fileHandler.WriteFiles(files, filepath.Join(dir, module.Dir)); err != nil {
+			return errors.Errorf("writing %s: %w", module.Repo, err)
+		}
+		bufYaml.Modules = append(bufYaml.Modules, moduleEntry{Path: module.Dir})
+	}
+
+	content, err := yaml.Marshal(bufYaml)
+	if err != nil {
+		return errors.Errorf("marshaling buf.yaml: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Errorf("creating workspace: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "buf.yaml"), content, 0644); err != nil {
+		return errors.Errorf("writing buf.yaml: %w", err)
+	}
+
+	return nil
+}
+
+// attribute finds the module a reported file belongs to, returning its repo and the file's path
+// within the module. buf may report paths relative to the workspace or including it.
+func attribute(path string, workspace string, modules []*Module) (string, string) {
+	path = filepath.ToSlash(path)
+	if _, rest, ok := strings.Cut(path, filepath.ToSlash(workspace)+"/"); ok {
+		path = rest
+	}
+
+	for _, module := range modules {
+		if rest, ok := strings.CutPrefix(path, module.Dir+"/"); ok {
+			return module.Repo, rest
+		}
+	}
+	for _, module := range modules {
+		if slices.ContainsFunc(module.Current, func(f *file.File) bool { return f.Path == path }) {
+			return module.Repo, path
+		}
+	}
+
+	return "", path
+}
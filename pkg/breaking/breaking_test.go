@@ -0,0 +1,87 @@
+package breaking
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+type fakeChecker struct {
+	check func(current string, against string) ([]*Violation, error)
+}
+
+func (c *fakeChecker) Check(ctx context.Context, current string, against string) ([]*Violation, error) {
+	return c.check(current, against)
+}
+
+func TestCheck(t *testing.T) {
+	modules := []*Module{
+		{
+			Repo:     "github.com/googleapis/googleapis",
+			Dir:      "googleapis",
+			Previous: []*file.File{{Path: "google/api/http.proto", Content: []byte("v1")}},
+			Current:  []*file.File{{Path: "google/api/http.proto", Content: []byte("v2")}},
+		},
+		{
+			Repo:    "github.com/example/new",
+			Dir:     "new",
+			Current: []*file.File{{Path: "example/new.proto", Content: []byte("new")}},
+		},
+	}
+
+	checker := &fakeChecker{check: func(current string, against string) ([]*Violation, error) {
+		content, err := os.ReadFile(filepath.Join(current, "googleapis", "google/api/http.proto"))
+		require.NoError(t, err)
+		assert.Equal(t, "v2", string(content))
+		content, err = os.ReadFile(filepath.Join(against, "googleapis", "google/api/http.proto"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1", string(content))
+
+		bufYaml, err := os.ReadFile(filepath.Join(current, "buf.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(bufYaml), "path: googleapis")
+		assert.Contains(t, string(bufYaml), "path: new")
+		assert.Contains(t, string(bufYaml), "- WIRE_JSON")
+
+		// The new module has no previous version to check against
+		bufYaml, err = os.ReadFile(filepath.Join(against, "buf.yaml"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(bufYaml), "path: new")
+
+		return []*Violation{
+			{Path: filepath.Join(current, "googleapis/google/api/http.proto"), Line: 3, Column: 1, Rule: "FIELD_NO_DELETE", Message: "Previously present field deleted."},
+			{Path: "google/api/http.proto", Line: 5, Column: 1, Rule: "FIELD_SAME_TYPE", Message: "Field changed type."},
+		}, nil
+	}}
+
+	violations, err := Check(context.Background(), checker, []string{"WIRE_JSON"}, modules)
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+
+	for _, violation := range violations {
+		assert.Equal(t, "github.com/googleapis/googleapis", violation.Repo)
+		assert.Equal(t, "google/api/http.proto", violation.Path)
+	}
+	assert.Equal(t, "github.com/googleapis/googleapis: google/api/http.proto:3:1: Previously present field deleted. (FIELD_NO_DELETE)", violations[0].String())
+}
+
+func TestParseAnnotations(t *testing.T) {
+	output := []byte(`{"path":"googleapis/google/api/http.proto","start_line":3,"start_column":1,"end_line":3,"end_column":20,"type":"FIELD_NO_DELETE","message":"Previously present field deleted."}
+
+{"path":"googleapis/google/api/client.proto","start_line":7,"start_column":2,"type":"ENUM_NO_DELETE","message":"Previously present enum deleted."}
+`)
+
+	violations, err := parseAnnotations(output)
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+	assert.Equal(t, &Violation{Path: "googleapis/google/api/http.proto", Line: 3, Column: 1, Rule: "FIELD_NO_DELETE", Message: "Previously present field deleted."}, violations[0])
+	assert.Equal(t, "ENUM_NO_DELETE", violations[1].Rule)
+
+	_, err = parseAnnotations([]byte("not json\n"))
+	assert.Error(t, err)
+}
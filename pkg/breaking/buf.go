@@ -0,0 +1,95 @@
+package breaking
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/bufbuild/buf/private/buf/cmd/buf"
+	"github.com/bufbuild/buf/private/pkg/app"
+	"github.com/bufbuild/buf/private/pkg/app/appcmd"
+	"gitlab.com/tozd/go/errors"
+)
+
+// BufChecker is a Checker running buf breaking in-process
+type BufChecker struct{}
+
+// NewBufChecker creates a new BufChecker
+func NewBufChecker() *BufChecker {
+	return &BufChecker{}
+}
+
+// Check runs buf breaking on the current workspace against the previous one
+func (c *BufChecker) Check(ctx context.Context, current string, against string) ([]*Violation, error) {
+	var stdout, stderr bytes.Buffer
+	container := app.NewContainer(environ(), strings.NewReader(""), &stdout, &stderr,
+		"buf", "breaking", current, "--against", against, "--error-format", "json")
+
+	runErr := appcmd.Run(ctx, container, buf.NewRootCommand("buf"))
+
+	violations, err := parseAnnotations(stdout.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// buf fails whenever it reports annotations, so only a failure without any is an error
+	if runErr != nil && len(violations) == 0 {
+		return nil, errors.Errorf("buf breaking: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return violations, nil
+}
+
+// annotation is a file annotation printed by buf with --error-format json
+type annotation struct {
+	Path        string `json:"path"`
+	StartLine   int    `json:"start_line"`
+	StartColumn int    `json:"start_column"`
+	Type        string `json:"type"`
+	Message     string `json:"message"`
+}
+
+// parseAnnotations parses the file annotations buf prints one JSON object per line
+func parseAnnotations(output []byte) ([]*Violation, error) {
+	violations := []*Violation{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var a annotation
+		if err := json.Unmarshal(line, &a); err != nil {
+			return nil, errors.Errorf("parsing buf breaking output %q: %w", line, err)
+		}
+
+		violations = append(violations, &Violation{
+			Path:    a.Path,
+			Line:    a.StartLine,
+			Column:  a.StartColumn,
+			Rule:    a.Type,
+			Message: a.Message,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Errorf("reading buf breaking output: %w", err)
+	}
+
+	return violations, nil
+}
+
+// environ returns the process environment as a map, which buf reads its cache location from
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
@@ -0,0 +1,75 @@
+package breaking
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+const greetingV1 = `syntax = "proto3";
+
+package acme.v1;
+
+message Greeting {
+  string name = 1;
+  string text = 2;
+}
+`
+
+func TestBufChecker(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		current string
+		rules   []string
+	}{
+		{
+			name: "field removed",
+			current: `syntax = "proto3";
+
+package acme.v1;
+
+message Greeting {
+  string name = 1;
+}
+`,
+			rules: []string{"FIELD_NO_DELETE"},
+		},
+		{
+			name: "field added",
+			current: `syntax = "proto3";
+
+package acme.v1;
+
+message Greeting {
+  string name = 1;
+  string text = 2;
+  string locale = 3;
+}
+`,
+			rules: []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			modules := []*Module{{
+				Repo:     "github.com/acme/protos",
+				Dir:      "protos",
+				Previous: []*file.File{{Path: "acme/v1/greeting.proto", Content: []byte(greetingV1)}},
+				Current:  []*file.File{{Path: "acme/v1/greeting.proto", Content: []byte(test.current)}},
+			}}
+
+			violations, err := Check(context.Background(), NewBufChecker(), []string{"FILE"}, modules)
+			require.NoError(t, err)
+
+			rules := []string{}
+			for _, violation := range violations {
+				assert.Equal(t, "github.com/acme/protos", violation.Repo)
+				assert.Equal(t, "acme/v1/greeting.proto", violation.Path)
+				rules = append(rules, violation.Rule)
+			}
+			assert.Equal(t, test.rules, rules)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package bsr
+
+import (
+	"regexp"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// ModuleRef is a reference to a BSR module as listed in the buf.yaml deps section,
+// for example buf.build/googleapis/googleapis:<commit>
+type ModuleRef struct {
+	Remote string
+	Owner  string
+	Name   string
+	// Ref is the pinned commit or label, empty if the dependency is not pinned
+	Ref string
+}
+
+// ParseModuleRef parses a buf.yaml dependency reference
+func ParseModuleRef(ref string) (ModuleRef, error) {
+	name, pin, _ := strings.Cut(strings.TrimSpace(ref), ":")
+
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return ModuleRef{}, errors.Errorf("invalid module reference %q: expected remote/owner/name[:ref]", ref)
+	}
+
+	return ModuleRef{Remote: parts[0], Owner: parts[1], Name: parts[2], Ref: pin}, nil
+}
+
+// FullName returns the module name without its ref
+func (r ModuleRef) FullName() string {
+	return r.Remote + "/" + r.Owner + "/" + r.Name
+}
+
+// String returns the reference as written in buf.yaml
+func (r ModuleRef) String() string {
+	if r.Ref == "" {
+		return r.FullName()
+	}
+	return r.FullName() + ":" + r.Ref
+}
+
+// gitCommitPattern matches a full git commit SHA, which BSR labels of git-pushed modules often are
+var gitCommitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// IsGitCommit reports whether a pin or ref is a full git commit SHA, as opposed to a BSR commit ID
+// or a label such as a tag
+func IsGitCommit(ref string) bool {
+	return gitCommitPattern.MatchString(ref)
+}
+
+// Source describes the git repository a BSR module is built from
+type Source struct {
+	// Module is the full name of the BSR module
+	Module string `yaml:"module" json:"module"`
+	// Repo is the git repository without scheme, as used in buf3pd deps
+	Repo string `yaml:"repo" json:"repo"`
+	// Path is the directory within the repository holding the module's proto files
+	Path string `yaml:"path" json:"path"`
+	// Ref is the git ref the module is pushed from, used when the BSR pin has no git equivalent
+	Ref string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	// Filter optionally limits the vendored files to those the module contains
+	Filter []string `yaml:"filter,omitempty" json:"filter,omitempty"`
+}
+
+// knownSources maps well-known BSR modules to the repositories they are pushed from
+var knownSources = []Source{
+	{Module: "buf.build/bufbuild/protovalidate", Repo: "github.com/bufbuild/protovalidate", Path: "proto/protovalidate", Ref: "heads/main"},
+	{Module: "buf.build/bufbuild/reflect", Repo: "github.com/bufbuild/reflect", Path: "proto", Ref: "heads/main"},
+	{Module: "buf.build/cncf/xds", Repo: "github.com/cncf/xds", Path: ".", Ref: "heads/main"},
+	{Module: "buf.build/envoyproxy/envoy", Repo: "github.com/envoyproxy/envoy", Path: "api", Ref: "heads/main"},
+	{Module: "buf.build/envoyproxy/protoc-gen-validate", Repo: "github.com/bufbuild/protoc-gen-validate", Path: ".", Ref: "heads/main", Filter: []string{"validate/*.proto"}},
+	{Module: "buf.build/gogo/protobuf", Repo: "github.com/gogo/protobuf", Path: ".", Ref: "heads/master", Filter: []string{"gogoproto/*.proto"}},
+	{Module: "buf.build/googleapis/googleapis", Repo: "github.com/googleapis/googleapis", Path: ".", Ref: "heads/master"},
+	{Module: "buf.build/grpc/grpc", Repo: "github.com/grpc/grpc-proto", Path: ".", Ref: "heads/master"},
+	{Module: "buf.build/grpc-ecosystem/grpc-gateway", Repo: "github.com/grpc-ecosystem/grpc-gateway", Path: ".", Ref: "heads/main", Filter: []string{"protoc-gen-openapiv2/options/*.proto"}},
+	{Module: "buf.build/opentelemetry/opentelemetry", Repo: "github.com/open-telemetry/opentelemetry-proto", Path: ".", Ref: "heads/main"},
+	{Module: "buf.build/protocolbuffers/wellknowntypes", Repo: "github.com/protocolbuffers/protobuf", Path: "src", Ref: "heads/main", Filter: []string{"google/protobuf/*.proto"}},
+}
+
+// LookupSource returns the source repository of a well-known BSR module
+func LookupSource(module string) (Source, bool) {
+	for _, source := range knownSources {
+		if source.Module == module {
+			return source, true
+		}
+	}
+	return Source{}, false
+}
+
+// KnownSources returns the source repositories of all well-known BSR modules
+func KnownSources() []Source {
+	return append([]Source(nil), knownSources...)
+}
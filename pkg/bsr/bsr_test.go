@@ -0,0 +1,79 @@
+package bsr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModuleRef(t *testing.T) {
+	ref, err := ParseModuleRef("buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8")
+	require.NoError(t, err)
+	assert.Equal(t, ModuleRef{Remote: "buf.build", Owner: "googleapis", Name: "googleapis", Ref: "cc916c31859748a68fd229a3c8d7a2e8"}, ref)
+	assert.Equal(t, "buf.build/googleapis/googleapis", ref.FullName())
+	assert.Equal(t, "buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8", ref.String())
+
+	ref, err = ParseModuleRef("buf.build/bufbuild/protovalidate")
+	require.NoError(t, err)
+	assert.Empty(t, ref.Ref)
+
+	_, err = ParseModuleRef("googleapis/googleapis")
+	assert.Error(t, err)
+}
+
+func TestIsGitCommit(t *testing.T) {
+	assert.True(t, IsGitCommit("0123456789abcdef0123456789abcdef01234567"))
+	assert.False(t, IsGitCommit("cc916c31859748a68fd229a3c8d7a2e8"))
+	assert.False(t, IsGitCommit("v1.2.0"))
+	assert.False(t, IsGitCommit(""))
+}
+
+func TestLookupSource(t *testing.T) {
+	source, ok := LookupSource("buf.build/googleapis/googleapis")
+	require.True(t, ok)
+	assert.Equal(t, "github.com/googleapis/googleapis", source.Repo)
+
+	_, ok = LookupSource("buf.build/acme/unknown")
+	assert.False(t, ok)
+}
+
+func TestReadLock(t *testing.T) {
+	dir := t.TempDir()
+
+	v1 := `version: v1
+deps:
+  - remote: buf.build
+    owner: googleapis
+    repository: googleapis
+    commit: cc916c31859748a68fd229a3c8d7a2e8
+    digest: shake256:469b049d0eb04203d5272062636c078decefc96fec69739159c25d85349c50c34c7706918a8b216c5c27f76939df48452148cff8c5c3ae77fa6ba5c25c1b8bf8
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v1.lock"), []byte(v1), 0644))
+
+	lock, err := ReadLock(filepath.Join(dir, "v1.lock"))
+	require.NoError(t, err)
+	require.Len(t, lock.Deps, 1)
+	assert.Equal(t, "buf.build/googleapis/googleapis", lock.Deps[0].Module)
+	assert.Equal(t, "cc916c31859748a68fd229a3c8d7a2e8", lock.Deps[0].Commit)
+
+	v2 := `version: v2
+deps:
+  - name: buf.build/bufbuild/protovalidate
+    commit: a6c49f84cc0f4e038680d390392e2ab0
+    digest: b5:8f8b4d1bc2e7a34ce8a6f36a1fd5ba35aaf9f0f6f6b4b16ac2c6b7e4a8d2c16fbd6e1dbd0f02e8c5f2b1e0b96f49de10e3a8a7a7c2e90a07dc0b1b7b8c9d1e2f3
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v2.lock"), []byte(v2), 0644))
+
+	lock, err = ReadLock(filepath.Join(dir, "v2.lock"))
+	require.NoError(t, err)
+	require.Len(t, lock.Deps, 1)
+	assert.Equal(t, "buf.build/bufbuild/protovalidate", lock.Deps[0].Module)
+
+	// A missing buf.lock is empty
+	lock, err = ReadLock(filepath.Join(dir, "missing.lock"))
+	require.NoError(t, err)
+	assert.Empty(t, lock.Deps)
+}
@@ -0,0 +1,67 @@
+package bsr
+
+import (
+	"os"
+
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// LockDep is a dependency pinned in a buf.lock file
+type LockDep struct {
+	// Module is the full name of the BSR module
+	Module string
+	Commit string
+	Digest string
+}
+
+// Lock is a buf.lock file, listing both direct and transitive dependencies
+type Lock struct {
+	Version string
+	Deps    []LockDep
+}
+
+// bufLockFile covers the v1 and v2 buf.lock formats
+type bufLockFile struct {
+	Version string `yaml:"version"`
+	Deps    []struct {
+		// v2 names modules in full
+		Name string `yaml:"name"`
+		// v1 splits the module name into its parts
+		Remote     string `yaml:"remote"`
+		Owner      string `yaml:"owner"`
+		Repository string `yaml:"repository"`
+		Commit     string `yaml:"commit"`
+		Digest     string `yaml:"digest"`
+	} `yaml:"deps"`
+}
+
+// ReadLock reads a buf.lock file. A missing file yields an empty lock.
+func ReadLock(path string) (*Lock, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Lock{}, nil
+		}
+		return nil, errors.Errorf("reading buf.lock: %w", err)
+	}
+
+	var raw bufLockFile
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, errors.Errorf("unmarshalling buf.lock: %w", err)
+	}
+
+	lock := &Lock{Version: raw.Version}
+	for _, dep := range raw.Deps {
+		module := dep.Name
+		if module == "" {
+			module = dep.Remote + "/" + dep.Owner + "/" + dep.Repository
+		}
+		if _, err := ParseModuleRef(module); err != nil {
+			return nil, errors.Errorf("reading buf.lock: %w", err)
+		}
+		lock.Deps = append(lock.Deps, LockDep{Module: module, Commit: dep.Commit, Digest: dep.Digest})
+	}
+
+	return lock, nil
+}
@@ -0,0 +1,88 @@
+package bufdigest
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/buf/private/bufpkg/bufcas"
+	"github.com/bufbuild/buf/private/bufpkg/bufmodule"
+	"github.com/walteh/buf3pd/pkg/file"
+	"gitlab.com/tozd/go/errors"
+)
+
+// licenseFilePath is the license file buf includes in a module
+const licenseFilePath = "LICENSE"
+
+// docFilePaths are the documentation files buf includes in a module, in order of preference. Only
+// the first one present is part of the module.
+var docFilePaths = []string{"buf.md", "README.md", "README.markdown"}
+
+// B5 calculates the buf b5 module digest of a vendored module directory, as it would appear in a
+// buf.lock for a module with the same files and no dependencies. Like buf, it only digests the proto
+// files and the license and documentation files at the module root, ignoring any other file.
+func B5(files []*file.File) (string, error) {
+	files = moduleFiles(files)
+	if len(files) == 0 {
+		return "", errors.New("no files to digest")
+	}
+
+	fileNodes := make([]bufcas.FileNode, 0, len(files))
+	for _, f := range files {
+		digest, err := bufcas.NewDigestForContent(bytes.NewReader(f.Content))
+		if err != nil {
+			return "", errors.Errorf("calculating digest for %s: %w", f.Path, err)
+		}
+
+		fileNode, err := bufcas.NewFileNode(f.Path, digest)
+		if err != nil {
+			return "", errors.Errorf("creating file node for %s: %w", f.Path, err)
+		}
+
+		fileNodes = append(fileNodes, fileNode)
+	}
+
+	manifest, err := bufcas.NewManifest(fileNodes)
+	if err != nil {
+		return "", errors.Errorf("creating manifest: %w", err)
+	}
+
+	manifestBlob, err := bufcas.ManifestToBlob(manifest)
+	if err != nil {
+		return "", errors.Errorf("creating manifest blob: %w", err)
+	}
+
+	// A b5 digest is the digest of the files digest followed by the sorted b5 digests of the
+	// module's dependencies, joined by newlines. Vendored modules have no dependencies.
+	digestOfDigests, err := bufcas.NewDigestForContent(bytes.NewReader([]byte(manifestBlob.Digest().String())))
+	if err != nil {
+		return "", errors.Errorf("calculating digest of digests: %w", err)
+	}
+
+	moduleDigest, err := bufmodule.NewDigest(bufmodule.DigestTypeB5, digestOfDigests)
+	if err != nil {
+		return "", errors.Errorf("creating b5 digest: %w", err)
+	}
+
+	return moduleDigest.String(), nil
+}
+
+// moduleFiles returns the files buf considers part of a module: every proto file, the LICENSE file
+// at the root, and the first documentation file present at the root
+func moduleFiles(files []*file.File) []*file.File {
+	docFilePath := ""
+	for _, path := range docFilePaths {
+		if slices.ContainsFunc(files, func(f *file.File) bool { return f.Path == path }) {
+			docFilePath = path
+			break
+		}
+	}
+
+	matched := make([]*file.File, 0, len(files))
+	for _, f := range files {
+		if strings.HasSuffix(f.Path, ".proto") || f.Path == licenseFilePath || (docFilePath != "" && f.Path == docFilePath) {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,97 @@
+package bufdigest
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+// readModule reads the files of a module directory, with paths relative to it
+func readModule(t *testing.T, dir string) []*file.File {
+	t.Helper()
+	var files []*file.File
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, &file.File{Path: filepath.ToSlash(rel), Content: content})
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+// without returns the files except those at the given paths
+func without(files []*file.File, paths ...string) []*file.File {
+	return slices.DeleteFunc(slices.Clone(files), func(f *file.File) bool {
+		return slices.Contains(paths, f.Path)
+	})
+}
+
+func TestB5(t *testing.T) {
+	files := readModule(t, filepath.Join("testdata", "module"))
+
+	// The expected digests were computed outside of Go, with Python's hashlib.shake_256, from the
+	// manifest of the files buf includes in the module
+	tests := []struct {
+		name   string
+		files  []*file.File
+		digest string
+	}{
+		{
+			// The LICENSE and buf.md files at the root are part of the module, while LICENSE.txt,
+			// README.md, which buf.md takes precedence over, nested docs and other files are not
+			name:   "license and doc file",
+			files:  files,
+			digest: "b5:0bd8dba1d4bf59a5f364d4ab8adcf6b11947b9c96018b69f679957e131d1b3df0085a9f6f193cb991690dc48c90f0e7a3441f08c06d4cb703b31836d42338c3e",
+		},
+		{
+			name:   "next doc file",
+			files:  without(files, "buf.md"),
+			digest: "b5:83b70474cf11fd2aad26a220e60e89ff7f0e180d9eccb95b02a61521444e72cc0ea0cc2bb9d4ddc426b62f5caf2342dce4562003f6c1997edaeec7efdfc8d811",
+		},
+		{
+			name:   "proto files only",
+			files:  without(files, "LICENSE", "buf.md", "README.md"),
+			digest: "b5:8a16dded30101fe1b8571e8423ddd16cd21d668477e1d82a69acedeeb3107dd3b399d23be71e7a2b34df75905640fb0f31c82af489e1e6d47dfd9ea3ac48870b",
+		},
+		{
+			name:   "ignored files",
+			files:  without(files, "LICENSE.txt", "acme/README.md", "acme/v1/notes.txt"),
+			digest: "b5:0bd8dba1d4bf59a5f364d4ab8adcf6b11947b9c96018b69f679957e131d1b3df0085a9f6f193cb991690dc48c90f0e7a3441f08c06d4cb703b31836d42338c3e",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			digest, err := B5(test.files)
+			require.NoError(t, err)
+			assert.Equal(t, test.digest, digest)
+
+			// The digest does not depend on the order of the files
+			reversed := slices.Clone(test.files)
+			slices.Reverse(reversed)
+			digest, err = B5(reversed)
+			require.NoError(t, err)
+			assert.Equal(t, test.digest, digest)
+		})
+	}
+
+	_, err := B5(nil)
+	assert.Error(t, err)
+	_, err = B5([]*file.File{{Path: "notes.txt", Content: []byte("notes")}})
+	assert.Error(t, err)
+}
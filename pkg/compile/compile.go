@@ -0,0 +1,200 @@
+package compile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/walteh/buf3pd/pkg/file"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Module is the set of files vendored for a dependency, rooted at the dependency's directory as in
+// buf.yaml
+type Module struct {
+	Repo   string
+	Filter []string
+	Files  []*file.File
+}
+
+// Problem is an error found compiling the vendored files
+type Problem struct {
+	Repo    string `json:"repo"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	// Import is the missing import, if the problem is one
+	Import string `json:"import,omitempty"`
+	// Suspect is the dependency whose filter most likely dropped the missing import
+	Suspect string   `json:"suspect,omitempty"`
+	Filter  []string `json:"filter,omitempty"`
+}
+
+func (p *Problem) String() string {
+	s := fmt.Sprintf("%s: %s:%d:%d: %s", p.Repo, p.Path, p.Line, p.Column, p.Message)
+	if p.Suspect != "" {
+		s += fmt.Sprintf(" (filter [%s] of %s may exclude it)", strings.Join(p.Filter, ", "), p.Suspect)
+	}
+	return s
+}
+
+// Check compiles the files of all modules together, resolving imports across modules and the
+// well-known types. Missing imports are reported first, each naming the filtered dependency most
+// likely responsible; the files are only compiled when every import resolves.
+func Check(ctx context.Context, modules []*Module) ([]*Problem, error) {
+	sources := map[string]string{}
+	owners := map[string]*Module{}
+	paths := []string{}
+	for _, module := range modules {
+		for _, f := range module.Files {
+			if !strings.HasSuffix(f.Path, ".proto") {
+				continue
+			}
+			if _, ok := owners[f.Path]; ok {
+				// The first module vendoring a path is the one imports resolve to
+				continue
+			}
+			sources[f.Path] = string(f.Content)
+			owners[f.Path] = module
+			paths = append(paths, f.Path)
+		}
+	}
+	slices.Sort(paths)
+
+	resolver := protocompile.WithStandardImports(&protocompile.SourceResolver{
+		Accessor: protocompile.SourceAccessorFromMap(sources),
+	})
+
+	problems := []*Problem{}
+	for _, p := range paths {
+		missing, err := missingImports(p, sources[p], resolver)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range missing {
+			problem := &Problem{
+				Repo:    owners[p].Repo,
+				Path:    p,
+				Line:    imp.line,
+				Column:  imp.column,
+				Message: fmt.Sprintf("import %q is not vendored", imp.name),
+				Import:  imp.name,
+			}
+			if suspect := suspectModule(imp.name, owners[p], modules); suspect != nil {
+				problem.Suspect = suspect.Repo
+				problem.Filter = suspect.Filter
+			}
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	rep := reporter.NewReporter(func(err reporter.ErrorWithPos) error {
+		pos := err.GetPosition()
+		problem := &Problem{
+			Path:    pos.Filename,
+			Line:    pos.Line,
+			Column:  pos.Col,
+			Message: err.Unwrap().Error(),
+		}
+		if owner, ok := owners[pos.Filename]; ok {
+			problem.Repo = owner.Repo
+		}
+		problems = append(problems, problem)
+		return nil
+	}, nil)
+
+	compiler := protocompile.Compiler{
+		Resolver: resolver,
+		Reporter: rep,
+	}
+	if _, err := compiler.Compile(ctx, paths...); err != nil && !errors.Is(err, reporter.ErrInvalidSource) {
+		return nil, errors.Errorf("compiling vendored files: %w", err)
+	}
+
+	return problems, nil
+}
+
+// importStatement is an import of a file, with its position
+type importStatement struct {
+	name   string
+	line   int
+	column int
+}
+
+// missingImports parses a file and returns the imports the resolver cannot find. Files that do not
+// parse have no missing imports; their syntax errors are reported when compiling.
+func missingImports(filename string, source string, resolver protocompile.Resolver) ([]*importStatement, error) {
+	handler := reporter.NewHandler(reporter.NewReporter(func(reporter.ErrorWithPos) error { return nil }, nil))
+	node, err := parser.Parse(filename, strings.NewReader(source), handler)
+	if err != nil || node == nil {
+		return nil, nil
+	}
+
+	missing := []*importStatement{}
+	for _, decl := range node.Decls {
+		imp, ok := decl.(*ast.ImportNode)
+		if !ok {
+			continue
+		}
+		name := imp.Name.AsString()
+
+		result, err := resolver.FindFileByPath(name)
+		if err != nil {
+			pos := node.NodeInfo(imp.Name).Start()
+			missing = append(missing, &importStatement{name: name, line: pos.Line, column: pos.Col})
+			continue
+		}
+		if closer, ok := result.Source.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+
+	return missing, nil
+}
+
+// suspectModule returns the filtered module most likely to have dropped a missing import: the one
+// vendoring files closest to it in the directory tree, or the importing module itself. Modules
+// without a filter vendor everything under their path, so they cannot be responsible.
+func suspectModule(missing string, importer *Module, modules []*Module) *Module {
+	var suspect *Module
+	best := 0
+	for _, module := range modules {
+		if len(module.Filter) == 0 {
+			continue
+		}
+		for _, f := range module.Files {
+			if shared := sharedDirs(missing, f.Path); shared > best {
+				suspect, best = module, shared
+			}
+		}
+	}
+	if suspect != nil {
+		return suspect
+	}
+	if len(importer.Filter) > 0 {
+		return importer
+	}
+	return nil
+}
+
+// sharedDirs counts the leading directories two slash-separated paths have in common
+func sharedDirs(a string, b string) int {
+	dirsA := strings.Split(path.Dir(a), "/")
+	dirsB := strings.Split(path.Dir(b), "/")
+	shared := 0
+	for shared < len(dirsA) && shared < len(dirsB) && dirsA[shared] == dirsB[shared] && dirsA[shared] != "." {
+		shared++
+	}
+	return shared
+}
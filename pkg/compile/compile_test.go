@@ -0,0 +1,103 @@
+package compile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+func protoFile(path string, content string) *file.File {
+	return &file.File{Path: path, Content: []byte(content)}
+}
+
+func TestCheck(t *testing.T) {
+	googleapis := &Module{
+		Repo:   "github.com/googleapis/googleapis",
+		Filter: []string{"google/api/**"},
+		Files: []*file.File{
+			protoFile("google/api/http.proto", `syntax = "proto3";
+package google.api;
+message HttpRule { string get = 1; }
+`),
+			protoFile("google/rpc/code.proto", `syntax = "proto3";
+package google.rpc;
+enum Code { OK = 0; }
+`),
+		},
+	}
+
+	t.Run("imports resolve across modules and well-known types", func(t *testing.T) {
+		example := &Module{
+			Repo: "github.com/example/api",
+			Files: []*file.File{
+				protoFile("example/v1/service.proto", `syntax = "proto3";
+package example.v1;
+import "google/api/http.proto";
+import "google/protobuf/timestamp.proto";
+message Request {
+  google.api.HttpRule rule = 1;
+  google.protobuf.Timestamp time = 2;
+}
+`),
+			},
+		}
+
+		problems, err := Check(context.Background(), []*Module{googleapis, example})
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("missing import names the filtered dependency", func(t *testing.T) {
+		example := &Module{
+			Repo: "github.com/example/api",
+			Files: []*file.File{
+				protoFile("example/v1/service.proto", `syntax = "proto3";
+package example.v1;
+import "google/rpc/status.proto";
+message Response {
+  google.rpc.Status status = 1;
+}
+`),
+			},
+		}
+
+		problems, err := Check(context.Background(), []*Module{googleapis, example})
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+
+		problem := problems[0]
+		assert.Equal(t, "github.com/example/api", problem.Repo)
+		assert.Equal(t, "example/v1/service.proto", problem.Path)
+		assert.Equal(t, 3, problem.Line)
+		assert.Equal(t, "google/rpc/status.proto", problem.Import)
+		assert.Equal(t, "github.com/googleapis/googleapis", problem.Suspect)
+		assert.Equal(t, []string{"google/api/**"}, problem.Filter)
+		assert.Equal(t, `github.com/example/api: example/v1/service.proto:3:8: import "google/rpc/status.proto" is not vendored (filter [google/api/**] of github.com/googleapis/googleapis may exclude it)`, problem.String())
+	})
+
+	t.Run("compile errors are attributed to their dependency", func(t *testing.T) {
+		example := &Module{
+			Repo: "github.com/example/api",
+			Files: []*file.File{
+				protoFile("example/v1/service.proto", `syntax = "proto3";
+package example.v1;
+message Request {
+  Unknown field = 1;
+}
+`),
+			},
+		}
+
+		problems, err := Check(context.Background(), []*Module{example})
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, "github.com/example/api", problems[0].Repo)
+		assert.Equal(t, "example/v1/service.proto", problems[0].Path)
+		assert.Equal(t, 4, problems[0].Line)
+		assert.Contains(t, problems[0].Message, "Unknown")
+		assert.Empty(t, problems[0].Suspect)
+	})
+}
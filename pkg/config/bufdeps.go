@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/bsr"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Modes for buf.yaml deps that are also vendored by buf3pd
+const (
+	BSRDepsWarn   = "warn"
+	BSRDepsRemove = "remove"
+	BSRDepsIgnore = "ignore"
+)
+
+// BSRDepsMode returns the configured mode for vendored buf.yaml deps, defaulting to warn
+func (c *Config) BSRDepsMode() (string, error) {
+	switch c.BSRDeps {
+	case "":
+		return BSRDepsWarn, nil
+	case BSRDepsWarn, BSRDepsRemove, BSRDepsIgnore:
+		return c.BSRDeps, nil
+	default:
+		return "", errors.Errorf("unknown bsr_deps mode %q: expected %s, %s or %s", c.BSRDeps, BSRDepsWarn, BSRDepsRemove, BSRDepsIgnore)
+	}
+}
+
+// VendoredBufDep is a buf.yaml dep that a buf3pd dependency also vendors
+type VendoredBufDep struct {
+	// BufDep is the entry as written in buf.yaml
+	BufDep string `json:"buf_dep"`
+	Module string `json:"module"`
+	// Pin is the commit or label the entry is pinned to, if any
+	Pin  string `json:"pin,omitempty"`
+	Repo string `json:"repo"`
+	Path string `json:"path"`
+	Ref  string `json:"ref"`
+	// Commit is the commit locked for the buf3pd dependency, if it is locked
+	Commit string `json:"commit,omitempty"`
+	// PinMismatch is set when Pin is a git commit that differs from Commit
+	PinMismatch bool `json:"pin_mismatch,omitempty"`
+}
+
+// VendoredBufDeps returns the buf.yaml deps covered by a buf3pd dependency, either through the
+// dependency's bsr field or the known source repository of the BSR module
+func VendoredBufDeps(bufYaml *BufYaml, deps []Buf3pdDep) []VendoredBufDep {
+	vendored := []VendoredBufDep{}
+	for _, bufDep := range bufYaml.Deps {
+		ref, err := bsr.ParseModuleRef(bufDep)
+		if err != nil {
+			continue
+		}
+
+		for _, dep := range deps {
+			if !coversModule(dep, ref.FullName()) {
+				continue
+			}
+			vendored = append(vendored, VendoredBufDep{
+				BufDep: bufDep,
+				Module: ref.FullName(),
+				Pin:    ref.Ref,
+				Repo:   dep.Repo,
+				Path:   dep.Path,
+				Ref:    dep.Ref,
+			})
+			break
+		}
+	}
+
+	return vendored
+}
+
+// coversModule reports whether a buf3pd dependency vendors a BSR module
+func coversModule(dep Buf3pdDep, module string) bool {
+	if dep.BSR != "" {
+		return dep.BSR == module
+	}
+	source, ok := bsr.LookupSource(module)
+	return ok && source.Repo == strings.TrimSuffix(dep.Repo, ".git")
+}
+
+// RemoveBufYamlDeps removes the given entries from the buf.yaml deps section, dropping the section
+// entirely once it is empty. The rest of the file is preserved.
+func (r *FileReader) RemoveBufYamlDeps(ctx context.Context, path string, refs []string) error {
+	log := zerolog.Ctx(ctx)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return err
+	}
+
+	_, original := doc.get("deps")
+	if original == nil || original.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	remove := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		remove[ref] = true
+	}
+
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: original.Style, LineComment: original.LineComment}
+	for _, item := range original.Content {
+		if remove[item.Value] {
+			log.Info().Str("dep", item.Value).Msg("removed vendored dep from buf.yaml")
+			continue
+		}
+		sequence.Content = append(sequence.Content, item)
+	}
+
+	if len(sequence.Content) == len(original.Content) {
+		return nil
+	}
+
+	var outputContent []byte
+	if len(sequence.Content) == 0 {
+		outputContent = doc.delete("deps")
+	} else {
+		outputContent, err = doc.set("deps", sequence)
+		if err != nil {
+			return errors.Errorf("updating deps: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(path, outputContent, 0644); err != nil {
+		return errors.Errorf("writing buf.yaml: %w", err)
+	}
+
+	return nil
+}
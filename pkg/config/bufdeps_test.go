@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendoredBufDeps(t *testing.T) {
+	bufYaml := &BufYaml{
+		Deps: []string{
+			"buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8",
+			"buf.build/bufbuild/protovalidate",
+			"buf.build/acme/internal",
+		},
+	}
+	deps := []Buf3pdDep{
+		{Type: "git", Repo: "github.com/googleapis/googleapis", Path: ".", Ref: "heads/master"},
+		{Type: "git", Repo: "github.com/acme/protos", Path: "internal", Ref: "main", BSR: "buf.build/acme/internal"},
+	}
+
+	vendored := VendoredBufDeps(bufYaml, deps)
+	assert.Equal(t, []VendoredBufDep{
+		{
+			BufDep: "buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8",
+			Module: "buf.build/googleapis/googleapis",
+			Pin:    "cc916c31859748a68fd229a3c8d7a2e8",
+			Repo:   "github.com/googleapis/googleapis",
+			Path:   ".",
+			Ref:    "heads/master",
+		},
+		{
+			BufDep: "buf.build/acme/internal",
+			Module: "buf.build/acme/internal",
+			Repo:   "github.com/acme/protos",
+			Path:   "internal",
+			Ref:    "main",
+		},
+	}, vendored)
+}
+
+func TestRemoveBufYamlDeps(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	input := `version: v2
+# pinned BSR deps
+deps:
+  - buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8
+  - buf.build/bufbuild/protovalidate # keep
+lint:
+  use:
+    - STANDARD
+`
+
+	bufYamlPath := filepath.Join(t.TempDir(), "buf.yaml")
+	require.NoError(t, os.WriteFile(bufYamlPath, []byte(input), 0644))
+
+	reader := NewFileReader()
+	require.NoError(t, reader.RemoveBufYamlDeps(ctx, bufYamlPath, []string{"buf.build/googleapis/googleapis:cc916c31859748a68fd229a3c8d7a2e8"}))
+
+	actual, err := os.ReadFile(bufYamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, `version: v2
+# pinned BSR deps
+deps:
+  - buf.build/bufbuild/protovalidate # keep
+lint:
+  use:
+    - STANDARD
+`, string(actual))
+
+	// Removing the last dep drops the section
+	require.NoError(t, reader.RemoveBufYamlDeps(ctx, bufYamlPath, []string{"buf.build/bufbuild/protovalidate"}))
+
+	actual, err = os.ReadFile(bufYamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, `version: v2
+# pinned BSR deps
+lint:
+  use:
+    - STANDARD
+`, string(actual))
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// BufWorkFileName is the name of the buf v1 workspace file
+const BufWorkFileName = "buf.work.yaml"
+
+// Workspace layouts buf3pd can register vendored modules in
+const (
+	// WorkspaceV1 lists module directories in buf.work.yaml, each with its own buf.yaml
+	WorkspaceV1 = "v1"
+	// WorkspaceV2 lists modules in the buf.yaml modules section
+	WorkspaceV2 = "v2"
+)
+
+// WorkspaceVersion detects the layout of the workspace holding buf.yaml. A buf.work.yaml next to
+// buf.yaml makes it a v1 workspace unless buf.yaml itself is v2, which buf then uses instead.
+func WorkspaceVersion(bufYamlPath string) (string, error) {
+	version := ""
+	content, err := os.ReadFile(bufYamlPath)
+	switch {
+	case err == nil:
+		bufYaml, err := readBufYamlWithMultiDoc(content)
+		if err != nil {
+			return "", err
+		}
+		version = bufYaml.Version
+	case !os.IsNotExist(err):
+		return "", errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	if version == "v2" {
+		return WorkspaceV2, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(bufYamlPath), BufWorkFileName)); err == nil {
+		return WorkspaceV1, nil
+	}
+
+	return WorkspaceV2, nil
+}
+
+// bufWorkDirectory is an entry of the buf.work.yaml directories section
+type bufWorkDirectory struct {
+	// node is the original entry, nil for new entries
+	node    *yaml.Node
+	path    string
+	managed bool
+}
+
+// bufYamlV1 is the buf.yaml written into the directory of a vendored dependency in a v1 workspace
+type bufYamlV1 struct {
+	Version  string                 `yaml:"version"`
+	Build    *bufYamlV1Build        `yaml:"build,omitempty"`
+	Lint     map[string]interface{} `yaml:"lint,omitempty"`
+	Breaking map[string]interface{} `yaml:"breaking,omitempty"`
+}
+
+// bufYamlV1Build is the build section of a v1 buf.yaml
+type bufYamlV1Build struct {
+	Excludes []string `yaml:"excludes,omitempty"`
+}
+
+// bufWorkPlan holds the edits that register dependencies in a v1 workspace
+type bufWorkPlan struct {
+	doc     *bufYamlDocument
+	entries []*bufWorkDirectory
+	// bufYamls maps the path of each per-directory buf.yaml to write to its content
+	bufYamls map[string][]byte
+	changes  []ModuleChange
+}
+
+// planBufWork reconciles the buf3pd-managed directories of buf.work.yaml with the dependencies,
+// and the buf.yaml of each vendored directory with the dependency's module settings. Directories
+// and buf.yaml files without the buf3pd marker are left untouched.
+func planBufWork(workPath string, outputPath string, deps []Buf3pdDep) (*bufWorkPlan, error) {
+	content, err := os.ReadFile(workPath)
+	if err != nil {
+		return nil, errors.Errorf("reading buf.work.yaml: %w", err)
+	}
+
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []*bufWorkDirectory
+	if _, original := doc.get("directories"); original != nil && original.Kind == yaml.SequenceNode {
+		for _, item := range original.Content {
+			existing = append(existing, &bufWorkDirectory{
+				node:    item,
+				path:    filepath.Clean(item.Value),
+				managed: strings.Contains(item.HeadComment, managedModuleComment),
+			})
+		}
+	}
+
+	plan := &bufWorkPlan{doc: doc, bufYamls: make(map[string][]byte), changes: []ModuleChange{}}
+
+	desired := make(map[string]BufModule)
+	var order []string
+	for _, dep := range deps {
+		module := BufModuleFor(outputPath, dep)
+		if _, ok := desired[module.Path]; ok {
+			continue
+		}
+		desired[module.Path] = module
+		order = append(order, module.Path)
+
+		bufYamlPath := filepath.Join(filepath.Dir(workPath), module.Path, "buf.yaml")
+		changed, content, err := bufYamlV1For(bufYamlPath, dep)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			plan.bufYamls[bufYamlPath] = content
+		}
+	}
+
+	for _, entry := range existing {
+		module, ok := desired[entry.path]
+		switch {
+		case ok:
+			delete(desired, entry.path)
+			if _, write := plan.bufYamls[filepath.Join(filepath.Dir(workPath), entry.path, "buf.yaml")]; write {
+				plan.changes = append(plan.changes, ModuleChange{Action: ModuleUpdated, Module: module})
+			}
+		case entry.managed:
+			plan.changes = append(plan.changes, ModuleChange{Action: ModuleRemoved, Module: BufModule{Path: entry.path}})
+			continue
+		}
+		plan.entries = append(plan.entries, entry)
+	}
+
+	for _, path := range order {
+		module, ok := desired[path]
+		if !ok {
+			continue
+		}
+		plan.changes = append(plan.changes, ModuleChange{Action: ModuleAdded, Module: module})
+		plan.entries = append(plan.entries, &bufWorkDirectory{path: path, managed: true})
+	}
+
+	return plan, nil
+}
+
+// bufYamlV1For renders the buf.yaml of a vendored directory and reports whether it differs from
+// the one on disk. A buf.yaml without the buf3pd marker is hand-written and never replaced.
+func bufYamlV1For(path string, dep Buf3pdDep) (bool, []byte, error) {
+	bufYaml := bufYamlV1{Version: "v1"}
+	// v1 modules have no includes; excludes, lint and breaking are relative to the directory
+	if dep.Module != nil {
+		if len(dep.Module.Excludes) > 0 {
+			bufYaml.Build = &bufYamlV1Build{Excludes: dep.Module.Excludes}
+		}
+		bufYaml.Lint = dep.Module.Lint
+		bufYaml.Breaking = dep.Module.Breaking
+	}
+
+	rendered, err := yaml.Marshal(bufYaml)
+	if err != nil {
+		return false, nil, errors.Errorf("encoding buf.yaml for %s: %w", dep.Repo, err)
+	}
+	rendered = append([]byte(managedModuleComment+"\n"), rendered...)
+
+	current, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return true, rendered, nil
+	case err != nil:
+		return false, nil, errors.Errorf("reading buf.yaml: %w", err)
+	case !bytes.Contains(current, []byte(managedModuleComment)):
+		return false, nil, nil
+	}
+
+	return !bytes.Equal(current, rendered), rendered, nil
+}
+
+// apply writes buf.work.yaml and the per-directory buf.yaml files
+func (p *bufWorkPlan) apply(ctx context.Context, workPath string) error {
+	log := zerolog.Ctx(ctx)
+
+	for path, content := range p.bufYamls {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return errors.Errorf("creating module directory: %w", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return errors.Errorf("writing buf.yaml: %w", err)
+		}
+		log.Info().Str("path", path).Msg("wrote buf.yaml for vendored module")
+	}
+
+	directoriesChanged := false
+	for _, change := range p.changes {
+		log.Info().Str("path", change.Module.Path).Msgf("buf.work.yaml directory %s", change.Action)
+		directoriesChanged = directoriesChanged || change.Action != ModuleUpdated
+	}
+	if !directoriesChanged {
+		return nil
+	}
+
+	_, original := p.doc.get("directories")
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if original != nil {
+		sequence.LineComment = original.LineComment
+	}
+	for _, entry := range p.entries {
+		item := entry.node
+		if item == nil {
+			item = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.path, HeadComment: managedModuleComment}
+		}
+		sequence.Content = append(sequence.Content, item)
+	}
+
+	content, err := p.doc.set("directories", sequence)
+	if err != nil {
+		return errors.Errorf("updating directories: %w", err)
+	}
+
+	if err := os.WriteFile(workPath, content, 0644); err != nil {
+		return errors.Errorf("writing buf.work.yaml: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		bufYaml string
+		bufWork bool
+		want    string
+	}{
+		{name: "v2", bufYaml: "version: v2\n", want: WorkspaceV2},
+		{name: "v2 ignores buf.work.yaml", bufYaml: "version: v2\n", bufWork: true, want: WorkspaceV2},
+		{name: "v1 workspace", bufWork: true, want: WorkspaceV1},
+		{name: "v1 workspace with root buf.yaml", bufYaml: "version: v1\n", bufWork: true, want: WorkspaceV1},
+		{name: "v1 without buf.work.yaml", bufYaml: "version: v1\n", want: WorkspaceV2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.bufYaml != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "buf.yaml"), []byte(tt.bufYaml), 0644))
+			}
+			if tt.bufWork {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, BufWorkFileName), []byte("version: v1\n"), 0644))
+			}
+
+			version, err := WorkspaceVersion(filepath.Join(dir, "buf.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, version)
+		})
+	}
+}
+
+func TestEnsureModulesInBufWorkYaml(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	dir := t.TempDir()
+	bufYamlPath := filepath.Join(dir, "buf.yaml")
+	bufWorkPath := filepath.Join(dir, BufWorkFileName)
+	require.NoError(t, os.WriteFile(bufWorkPath, []byte(`version: v1
+directories:
+  - proto # our own protos
+`), 0644))
+
+	deps := []Buf3pdDep{
+		{Type: "git", Repo: "github.com/example/repo1", Path: "proto", Ref: "main"},
+		{Type: "git", Repo: "github.com/example/repo2", Path: "proto", Ref: "main", Module: &ModuleConfig{
+			Excludes: []string{"internal"},
+			Lint:     map[string]interface{}{"except": []interface{}{"ALL"}},
+		}},
+	}
+
+	reader := NewFileReader()
+	require.NoError(t, reader.EnsureModulesInBufYaml(ctx, bufYamlPath, "gen/buf3pd", deps))
+
+	bufWork, err := os.ReadFile(bufWorkPath)
+	require.NoError(t, err)
+	assert.Equal(t, `version: v1
+directories:
+  - proto # our own protos
+  # managed by buf3pd
+  - gen/buf3pd/repo1
+  # managed by buf3pd
+  - gen/buf3pd/repo2
+`, string(bufWork))
+
+	repo1, err := os.ReadFile(filepath.Join(dir, "gen", "buf3pd", "repo1", "buf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "# managed by buf3pd\nversion: v1\n", string(repo1))
+
+	repo2, err := os.ReadFile(filepath.Join(dir, "gen", "buf3pd", "repo2", "buf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, `# managed by buf3pd
+version: v1
+build:
+    excludes:
+        - internal
+lint:
+    except:
+        - ALL
+`, string(repo2))
+
+	// Nothing changes on a second run
+	changes, err := reader.PlanModulesInBufYaml(ctx, bufYamlPath, "gen/buf3pd", deps)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Dropping a dependency removes its directory
+	changes, err = reader.PlanModulesInBufYaml(ctx, bufYamlPath, "gen/buf3pd", deps[:1])
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleChange{{Action: ModuleRemoved, Module: BufModule{Path: "gen/buf3pd/repo2"}}}, changes)
+
+	require.NoError(t, reader.EnsureModulesInBufYaml(ctx, bufYamlPath, "gen/buf3pd", deps[:1]))
+
+	bufWork, err = os.ReadFile(bufWorkPath)
+	require.NoError(t, err)
+	assert.Equal(t, `version: v1
+directories:
+  - proto # our own protos
+  # managed by buf3pd
+  - gen/buf3pd/repo1
+`, string(bufWork))
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultIndent is the indentation used for rewritten buf.yaml values when none can be detected
+const defaultIndent = 2
+
+// bufYamlDocument is the buf configuration document of a buf.yaml file. The original bytes are
+// kept so that edits to a single top-level key can be spliced in without reformatting the rest.
+type bufYamlDocument struct {
+	content []byte
+	// root is the top-level mapping of the configuration document, nil if the file is empty
+	root *yaml.Node
+}
+
+// parseBufYamlDocument parses buf.yaml content. When the file holds multiple YAML documents,
+// the second one is the buf configuration, matching the layout described in the README.
+func parseBufYamlDocument(content []byte) (*bufYamlDocument, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	var documents []*yaml.Node
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, errors.Errorf("parsing buf.yaml: %w", err)
+		}
+		documents = append(documents, &document)
+	}
+
+	doc := &bufYamlDocument{content: content}
+
+	var document *yaml.Node
+	switch {
+	case len(documents) >= 2:
+		document = documents[1]
+	case len(documents) == 1:
+		document = documents[0]
+	default:
+		return doc, nil
+	}
+
+	if len(document.Content) == 0 {
+		return doc, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.Errorf("buf.yaml document is not a mapping (line %d)", root.Line)
+	}
+	doc.root = root
+
+	return doc, nil
+}
+
+// decode decodes the configuration document into out
+func (d *bufYamlDocument) decode(out any) error {
+	if d.root == nil {
+		return nil
+	}
+	return d.root.Decode(out)
+}
+
+// get returns the key and value nodes of a top-level key, or nil if the key is not present
+func (d *bufYamlDocument) get(key string) (*yaml.Node, *yaml.Node) {
+	if d.root == nil {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		if d.root.Content[i].Value == key {
+			return d.root.Content[i], d.root.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// set replaces the value of a top-level key, or appends the key to the end of the document,
+// and returns the new file content. Only the lines holding the key and its value are rewritten.
+func (d *bufYamlDocument) set(key string, value *yaml.Node) ([]byte, error) {
+	lines := strings.SplitAfter(string(d.content), "\n")
+
+	keyNode, valueNode := d.get(key)
+	if keyNode == nil {
+		keyNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		rendered, err := render(keyNode, value, defaultIndent)
+		if err != nil {
+			return nil, err
+		}
+
+		// Insert after the last key of the document, or at the end of an empty file
+		insertAt := len(lines)
+		if d.root != nil && len(d.root.Content) > 0 {
+			insertAt = d.valueEndLine(lines, len(d.root.Content)-2)
+		}
+
+		return splice(lines, insertAt, insertAt, rendered), nil
+	}
+
+	indent := defaultIndent
+	if len(valueNode.Content) > 0 && valueNode.Kind == yaml.SequenceNode && valueNode.Style&yaml.FlowStyle == 0 {
+		// Items start two columns after their "- " indicator
+		if detected := valueNode.Content[0].Column - 2 - keyNode.Column; detected > 0 {
+			indent = detected
+		}
+	}
+
+	// The key's head comment sits above the spliced lines and is left untouched
+	renderedKey := *keyNode
+	renderedKey.HeadComment = ""
+	clearTrailingFootComments(value)
+
+	rendered, err := render(&renderedKey, value, indent)
+	if err != nil {
+		return nil, err
+	}
+
+	index := d.keyIndex(key)
+
+	return splice(lines, keyNode.Line-1, d.valueEndLine(lines, index), rendered), nil
+}
+
+// delete removes a top-level key and its value, and returns the new file content. The key's head
+// comment is kept in place.
+func (d *bufYamlDocument) delete(key string) []byte {
+	keyNode, _ := d.get(key)
+	if keyNode == nil {
+		return d.content
+	}
+
+	lines := strings.SplitAfter(string(d.content), "\n")
+	return splice(lines, keyNode.Line-1, d.valueEndLine(lines, d.keyIndex(key)), "")
+}
+
+// keyIndex returns the index of a top-level key node in the root mapping's content
+func (d *bufYamlDocument) keyIndex(key string) int {
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		if d.root.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// valueEndLine returns the index of the first line after the value of the key at keyIndex,
+// leaving trailing blank lines, comments and document markers outside of the value
+func (d *bufYamlDocument) valueEndLine(lines []string, keyIndex int) int {
+	last := maxLine(d.root.Content[keyIndex+1])
+
+	// The value ends before the next key, or at the end of the file for the last key
+	end := len(lines)
+	if keyIndex+2 < len(d.root.Content) {
+		end = d.root.Content[keyIndex+2].Line - 1
+	}
+
+	for end > last {
+		trimmed := strings.TrimSpace(lines[end-1])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && trimmed != "---" && trimmed != "..." {
+			break
+		}
+		end--
+	}
+
+	return end
+}
+
+// maxLine returns the last line any node within the tree starts on
+func maxLine(node *yaml.Node) int {
+	last := node.Line
+	for _, child := range node.Content {
+		last = max(last, maxLine(child))
+	}
+	return last
+}
+
+// clearTrailingFootComments removes the foot comments that follow the last line of a value, which
+// stay in place in the original content
+func clearTrailingFootComments(node *yaml.Node) {
+	for node != nil {
+		node.FootComment = ""
+		if len(node.Content) == 0 {
+			return
+		}
+		node = node.Content[len(node.Content)-1]
+	}
+}
+
+// render encodes a single key and value as a top-level YAML mapping entry
+func render(key *yaml.Node, value *yaml.Node, indent int) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}); err != nil {
+		return "", errors.Errorf("encoding %s: %w", key.Value, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", errors.Errorf("encoding %s: %w", key.Value, err)
+	}
+	return buf.String(), nil
+}
+
+// splice replaces lines[start:end] with the rendered text
+func splice(lines []string, start int, end int, rendered string) []byte {
+	var out strings.Builder
+	for _, line := range lines[:start] {
+		out.WriteString(line)
+	}
+	// Lines before an insertion point may lack a trailing newline at the end of the file
+	if start > 0 && !strings.HasSuffix(lines[start-1], "\n") && lines[start-1] != "" {
+		out.WriteString("\n")
+	}
+	out.WriteString(rendered)
+	for _, line := range lines[end:] {
+		out.WriteString(line)
+	}
+	return []byte(out.String())
+}
+
+// managedModuleComment marks the buf.yaml module entries buf3pd owns. Entries without it are
+// treated as hand-written and are never updated or removed.
+const managedModuleComment = "# managed by buf3pd"
+
+// bufYamlModule is an entry of the buf.yaml modules section
+type bufYamlModule struct {
+	// node is the original entry, nil if the entry is new or its module changed
+	node   *yaml.Node
+	module BufModule
+	// managed entries are written with the managedModuleComment marker
+	managed bool
+	// headComment is kept when an entry is rebuilt from its module
+	headComment string
+}
+
+// modules returns the entries of the modules section
+func (d *bufYamlDocument) modules() ([]*bufYamlModule, error) {
+	_, original := d.get("modules")
+	if original == nil || original.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+
+	entries := make([]*bufYamlModule, 0, len(original.Content))
+	for _, item := range original.Content {
+		var module BufModule
+		if err := item.Decode(&module); err != nil {
+			return nil, errors.Errorf("decoding module (line %d): %w", item.Line, err)
+		}
+		entries = append(entries, &bufYamlModule{
+			node:        item,
+			module:      module,
+			managed:     strings.Contains(item.HeadComment, managedModuleComment),
+			headComment: item.HeadComment,
+		})
+	}
+
+	return entries, nil
+}
+
+// matchModules maps modules onto the existing entries, reusing the entry of any module that is
+// unchanged so its comments and any keys BufModule does not model are preserved
+func matchModules(existing []*bufYamlModule, modules []BufModule) []*bufYamlModule {
+	used := make([]bool, len(existing))
+	entries := make([]*bufYamlModule, 0, len(modules))
+	for _, module := range modules {
+		var entry *bufYamlModule
+		for i, original := range existing {
+			if !used[i] && reflect.DeepEqual(original.module, module) {
+				used[i] = true
+				entry = original
+				break
+			}
+		}
+
+		if entry == nil {
+			entry = &bufYamlModule{module: module}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// modulesNode builds the modules sequence for the given entries
+func (d *bufYamlDocument) modulesNode(entries []*bufYamlModule) (*yaml.Node, error) {
+	_, original := d.get("modules")
+
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if original != nil {
+		sequence.LineComment = original.LineComment
+	}
+
+	for _, entry := range entries {
+		item := entry.node
+		if item == nil {
+			item = &yaml.Node{}
+			if err := item.Encode(entry.module); err != nil {
+				return nil, errors.Errorf("encoding module %s: %w", entry.module.Path, err)
+			}
+			item.HeadComment = entry.headComment
+			if entry.managed && !strings.Contains(item.HeadComment, managedModuleComment) {
+				item.HeadComment = managedModuleComment
+			}
+		}
+
+		sequence.Content = append(sequence.Content, item)
+	}
+
+	return sequence, nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestEnsureModulesInBufYamlGolden(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	deps := []Buf3pdDep{
+		{Type: "git", Repo: "github.com/example/repo1", Path: "proto", Ref: "main"},
+		{Type: "git", Repo: "github.com/example/repo2", Path: "proto", Ref: "main"},
+	}
+
+	inputs, err := filepath.Glob(filepath.Join("testdata", "bufyaml", "*.input.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.yaml")
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(input)
+			require.NoError(t, err)
+
+			bufYamlPath := filepath.Join(t.TempDir(), "buf.yaml")
+			require.NoError(t, os.WriteFile(bufYamlPath, content, 0644))
+
+			reader := NewFileReader()
+			require.NoError(t, reader.EnsureModulesInBufYaml(ctx, bufYamlPath, "gen/buf3pd", deps))
+
+			actual, err := os.ReadFile(bufYamlPath)
+			require.NoError(t, err)
+
+			goldenPath := filepath.Join("testdata", "bufyaml", name+".golden.yaml")
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(goldenPath, actual, 0644))
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestWriteBufYamlRoundTrip(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	inputs, err := filepath.Glob(filepath.Join("testdata", "bufyaml", "*.input.yaml"))
+	require.NoError(t, err)
+
+	for _, input := range inputs {
+		t.Run(strings.TrimSuffix(filepath.Base(input), ".input.yaml"), func(t *testing.T) {
+			content, err := os.ReadFile(input)
+			require.NoError(t, err)
+
+			bufYamlPath := filepath.Join(t.TempDir(), "buf.yaml")
+			require.NoError(t, os.WriteFile(bufYamlPath, content, 0644))
+
+			// Writing back the modules that were read must not change a single byte
+			reader := NewFileReader()
+			bufYaml, err := reader.ReadBufYaml(ctx, bufYamlPath)
+			require.NoError(t, err)
+			if len(bufYaml.Modules) == 0 {
+				t.Skip("no modules to write back")
+			}
+			require.NoError(t, reader.WriteBufYaml(ctx, bufYamlPath, bufYaml))
+
+			actual, err := os.ReadFile(bufYamlPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(content), string(actual))
+		})
+	}
+}
+
+func TestPlanModulesInBufYaml(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	deps := []Buf3pdDep{
+		{Type: "git", Repo: "github.com/example/repo1", Path: "proto", Ref: "main"},
+		{Type: "git", Repo: "github.com/example/repo2", Path: "proto", Ref: "main"},
+		{Type: "git", Repo: "github.com/example/repo3", Path: "proto", Ref: "main"},
+	}
+
+	reader := NewFileReader()
+	changes, err := reader.PlanModulesInBufYaml(ctx, filepath.Join("testdata", "bufyaml", "reconcile.input.yaml"), "gen/buf3pd", deps)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ModuleChange{
+		{Action: ModuleUpdated, Module: BufModule{Name: "github.com/example/repo1", Path: "gen/buf3pd/repo1"}},
+		{Action: ModuleRemoved, Module: BufModule{Name: "github.com/example/removed", Path: "gen/buf3pd/removed"}},
+		{Action: ModuleAdded, Module: BufModule{Name: "github.com/example/repo3", Path: "gen/buf3pd/repo3"}},
+	}, changes)
+}
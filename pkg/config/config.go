@@ -4,32 +4,88 @@ import (
 	"context"
 	"os"
 	"path/filepath"
-	"strings"
+	"reflect"
 
 	"github.com/rs/zerolog"
 	"gitlab.com/tozd/go/errors"
 	"gopkg.in/yaml.v3"
 )
 
-// Buf3pdDep represents a dependency in the buf3pd configuration
+// ConfigFileName is the name of the standalone buf3pd config file written by buf3pd
+const ConfigFileName = "buf.3pd.yaml"
+
+// Buf3pdDep represents a dependency in the buf3pd configuration. The jsonschema and description
+// tags feed the schema printed by buf3pd schema.
 type Buf3pdDep struct {
-	Type   string   `yaml:"type"`
-	Repo   string   `yaml:"repo"`
-	Path   string   `yaml:"path"`
-	Ref    string   `yaml:"ref"`
-	Filter []string `yaml:"filter"`
+	Type   string   `yaml:"type" json:"type" jsonschema:"required" description:"Dependency source, git or the type of a buf3pd-source-<type> plugin"`
+	Repo   string   `yaml:"repo" json:"repo" jsonschema:"required" description:"Repository without scheme, e.g. github.com/googleapis/googleapis"`
+	Path   string   `yaml:"path" json:"path" description:"Directory within the repository to vendor proto files from"`
+	Ref    string   `yaml:"ref" json:"ref" jsonschema:"required" description:"Git ref to resolve, e.g. heads/main, tags/v1.0.0 or a commit"`
+	Filter []string `yaml:"filter,omitempty" json:"filter" description:"Glob patterns the vendored proto files must match"`
+	// Module overrides the config-level module settings for this dependency's buf.yaml module
+	Module *ModuleConfig `yaml:"module,omitempty" json:"module,omitempty" description:"buf.yaml module settings, overriding the top-level module settings"`
+	// Policy holds the checks the dependency's vendored files and license must pass
+	Policy *PolicyConfig `yaml:"policy,omitempty" json:"policy,omitempty" description:"Checks the vendored files and the license of the dependency must pass"`
+	// BSR names the BSR module this dependency replaces, for modules buf3pd does not know the source of
+	BSR string `yaml:"bsr,omitempty" json:"bsr,omitempty" description:"BSR module this dependency replaces in buf.yaml deps"`
 }
 
 // Config represents the configuration structure in buf.yaml
 type Config struct {
-	Path string      `yaml:"path"`
-	Deps []Buf3pdDep `yaml:"deps"`
+	Path string `yaml:"path" json:"path" description:"Directory dependencies are vendored into"`
+	// Shared marks Path as a vendor directory shared with other projects, which may lie outside the
+	// working directory and is pruned only once no project uses a dependency anymore
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty" description:"Path is a vendor directory shared with other projects"`
+	// Module holds the default buf.yaml module settings for every vendored dependency
+	Module *ModuleConfig `yaml:"module,omitempty" json:"module,omitempty" description:"buf.yaml module settings for every vendored dependency"`
+	Deps   []Buf3pdDep   `yaml:"deps" json:"deps" jsonschema:"required" description:"Dependencies to vendor"`
+	// BSRDeps controls what happens to buf.yaml deps that are also vendored: warn (default), remove or ignore
+	BSRDeps string `yaml:"bsr_deps,omitempty" json:"bsr_deps,omitempty" jsonschema:"enum=warn|remove|ignore" description:"What to do with buf.yaml deps that are also vendored"`
+	// BreakingRules are the buf breaking rules checked when a dependency moves to a different commit
+	BreakingRules []string `yaml:"breaking_rules,omitempty" json:"breaking_rules,omitempty" description:"buf breaking rules, such as FILE or WIRE_JSON, a dependency moving to a new commit must pass"`
+	// Collisions controls which copy is vendored of a file several dependencies provide
+	Collisions *CollisionsConfig `yaml:"collisions,omitempty" json:"collisions,omitempty" description:"How files provided by several dependencies are resolved"`
+	// Mirrors maps upstream repository prefixes to the URLs git sources fetch them from instead
+	Mirrors map[string]string `yaml:"mirrors,omitempty" json:"mirrors,omitempty" description:"Mirror URLs by upstream repository prefix, e.g. github.com/googleapis"`
+
+	// File is the path the config was read from, a standalone config file or buf.yaml
+	File string `yaml:"-" json:"-"`
+}
+
+// ModuleConfig holds the buf v2 module settings written to a vendored dependency's module entry.
+// Includes and excludes are relative to the vendored module directory.
+type ModuleConfig struct {
+	Includes []string               `yaml:"includes,omitempty" json:"includes,omitempty" description:"Directories to include, relative to the vendored module"`
+	Excludes []string               `yaml:"excludes,omitempty" json:"excludes,omitempty" description:"Directories to exclude, relative to the vendored module"`
+	Lint     map[string]interface{} `yaml:"lint,omitempty" json:"lint,omitempty" description:"buf lint settings for the module"`
+	Breaking map[string]interface{} `yaml:"breaking,omitempty" json:"breaking,omitempty" description:"buf breaking settings for the module"`
+}
+
+// PolicyConfig declares the checks a dependency must pass to be vendored. Any violation fails the
+// sync.
+type PolicyConfig struct {
+	RequireLicense bool     `yaml:"require_license,omitempty" json:"require_license,omitempty" description:"Require a LICENSE or COPYING file at the root of the source repository"`
+	Licenses       []string `yaml:"licenses,omitempty" json:"licenses,omitempty" description:"SPDX identifiers of the allowed licenses, e.g. Apache-2.0"`
+	MaxFileSize    int64    `yaml:"max_file_size,omitempty" json:"max_file_size,omitempty" description:"Largest vendored file allowed, in bytes"`
+	RequirePackage bool     `yaml:"require_package,omitempty" json:"require_package,omitempty" description:"Require every vendored proto file to declare a package"`
+	GoPackage      string   `yaml:"go_package,omitempty" json:"go_package,omitempty" description:"Regular expression every go_package option must match"`
+}
+
+// CollisionsConfig resolves files provided by several dependencies, which buf would reject as
+// duplicates. Winners are matched before identical copies are deduplicated.
+type CollisionsConfig struct {
+	Dedupe  bool              `yaml:"dedupe,omitempty" json:"dedupe,omitempty" description:"Keep the first dependency's copy of a file several dependencies provide with identical content"`
+	Winners map[string]string `yaml:"winners,omitempty" json:"winners,omitempty" description:"Repository whose copy of a file is kept, by file path or glob, e.g. google/api/*.proto"`
 }
 
 // BufModule represents a module in the buf.yaml modules section
 type BufModule struct {
-	Name string `yaml:"name"`
-	Path string `yaml:"path"`
+	Name     string                 `yaml:"name,omitempty" json:"name,omitempty"`
+	Path     string                 `yaml:"path" json:"path"`
+	Includes []string               `yaml:"includes,omitempty" json:"includes,omitempty"`
+	Excludes []string               `yaml:"excludes,omitempty" json:"excludes,omitempty"`
+	Lint     map[string]interface{} `yaml:"lint,omitempty" json:"lint,omitempty"`
+	Breaking map[string]interface{} `yaml:"breaking,omitempty" json:"breaking,omitempty"`
 }
 
 // BufYaml represents the complete buf.yaml file structure
@@ -42,12 +98,28 @@ type BufYaml struct {
 	Buf3pd   *Config                `yaml:"buf3pd,omitempty"`
 }
 
+// Module change actions
+const (
+	ModuleAdded   = "added"
+	ModuleUpdated = "updated"
+	ModuleRemoved = "removed"
+)
+
+// ModuleChange describes an edit to a buf3pd-managed module in the buf.yaml modules section
+type ModuleChange struct {
+	Action string    `json:"action"`
+	Module BufModule `json:"module"`
+}
+
 // Reader provides an interface for reading configuration
 type Reader interface {
 	ReadConfig(ctx context.Context, workDir string, configPath string) (*Config, error)
+	ReadConfigFile(ctx context.Context, path string) (*Config, error)
 	ReadBufYaml(ctx context.Context, path string) (*BufYaml, error)
 	WriteBufYaml(ctx context.Context, path string, bufYaml *BufYaml) error
 	EnsureModulesInBufYaml(ctx context.Context, path string, outputPath string, deps []Buf3pdDep) error
+	PlanModulesInBufYaml(ctx context.Context, path string, outputPath string, deps []Buf3pdDep) ([]ModuleChange, error)
+	RemoveBufYamlDeps(ctx context.Context, path string, refs []string) error
 }
 
 // FileReader implements the Reader interface
@@ -58,64 +130,107 @@ func NewFileReader() *FileReader {
 	return &FileReader{}
 }
 
-// ReadConfig reads the buf3pd configuration, checking for a dedicated buf3pd.yaml file first
-func (r *FileReader) ReadConfig(ctx context.Context, workDir string, configPath string) (*Config, error) {
-	log := zerolog.Ctx(ctx)
-
-	// First try to read from buf3pd.yaml if it exists
-	buf3pdYamlPath := filepath.Join(workDir, "buf.3pd.yaml")
-	if _, err := os.Stat(buf3pdYamlPath); err == nil {
-		log.Info().Str("path", buf3pdYamlPath).Msg("reading buf3pd.yaml config")
+// applyModuleDefaults merges the config-level module settings into every dependency, with any
+// setting a dependency declares itself taking precedence
+func (c *Config) applyModuleDefaults() {
+	if c.Module == nil {
+		return
+	}
 
-		content, err := os.ReadFile(buf3pdYamlPath)
-		if err != nil {
-			return nil, errors.Errorf("reading buf3pd.yaml: %w", err)
+	for i := range c.Deps {
+		merged := *c.Module
+		if override := c.Deps[i].Module; override != nil {
+			if override.Includes != nil {
+				merged.Includes = override.Includes
+			}
+			if override.Excludes != nil {
+				merged.Excludes = override.Excludes
+			}
+			if override.Lint != nil {
+				merged.Lint = override.Lint
+			}
+			if override.Breaking != nil {
+				merged.Breaking = override.Breaking
+			}
 		}
+		c.Deps[i].Module = &merged
+	}
+}
 
-		var config Config
-		if err := yaml.Unmarshal(content, &config); err != nil {
-			return nil, errors.Errorf("unmarshalling buf3pd.yaml: %w", err)
-		}
+// BufModuleFor returns the buf.yaml module entry for a dependency vendored under outputPath
+func BufModuleFor(outputPath string, dep Buf3pdDep) BufModule {
+	modulePath := filepath.Join(outputPath, filepath.Base(dep.Repo))
 
-		if len(config.Deps) == 0 {
-			return nil, errors.New("buf3pd.yaml does not contain any dependencies")
-		}
+	module := BufModule{
+		Name: dep.Repo,
+		Path: modulePath,
+	}
 
-		return &config, nil
+	if dep.Module != nil {
+		for _, include := range dep.Module.Includes {
+			module.Includes = append(module.Includes, filepath.Join(modulePath, include))
+		}
+		for _, exclude := range dep.Module.Excludes {
+			module.Excludes = append(module.Excludes, filepath.Join(modulePath, exclude))
+		}
+		module.Lint = rebaseIgnores(dep.Module.Lint, modulePath)
+		module.Breaking = rebaseIgnores(dep.Module.Breaking, modulePath)
 	}
 
-	// Fall back to reading from buf.yaml
-	log.Info().Str("path", configPath).Msg("reading buf3pd config from buf.yaml")
+	return module
+}
 
-	// Read the full buf.yaml file
-	bufYaml, err := r.ReadBufYaml(ctx, configPath)
-	if err != nil {
-		return nil, errors.Errorf("reading buf.yaml: %w", err)
+// rebaseIgnores returns a copy of lint or breaking settings with the ignore and ignore_only paths,
+// which are relative to the vendored module, made relative to buf.yaml like v2 module paths
+func rebaseIgnores(settings map[string]interface{}, modulePath string) map[string]interface{} {
+	if settings == nil {
+		return nil
 	}
 
-	// Check if the Buf3pd section exists
-	if bufYaml.Buf3pd == nil || len(bufYaml.Buf3pd.Deps) == 0 {
-		return nil, errors.New("buf.yaml does not contain any buf3pd dependencies")
+	rebase := func(paths interface{}) interface{} {
+		list, ok := paths.([]interface{})
+		if !ok {
+			return paths
+		}
+		rebased := make([]interface{}, 0, len(list))
+		for _, path := range list {
+			if s, ok := path.(string); ok {
+				path = filepath.Join(modulePath, s)
+			}
+			rebased = append(rebased, path)
+		}
+		return rebased
 	}
 
-	return bufYaml.Buf3pd, nil
+	rebased := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		switch key {
+		case "ignore":
+			value = rebase(value)
+		case "ignore_only":
+			if rules, ok := value.(map[string]interface{}); ok {
+				rebasedRules := make(map[string]interface{}, len(rules))
+				for rule, paths := range rules {
+					rebasedRules[rule] = rebase(paths)
+				}
+				value = rebasedRules
+			}
+		}
+		rebased[key] = value
+	}
+
+	return rebased
 }
 
 // readBufYamlWithMultiDoc reads buf.yaml handling the multi-document format
 func readBufYamlWithMultiDoc(content []byte) (*BufYaml, error) {
-	// Split by "---" to find the sections
-	sections := strings.Split(string(content), "---")
-
-	// If there are multiple sections, we want the second one
-	var yamlContent string
-	if len(sections) >= 2 {
-		yamlContent = sections[1]
-	} else {
-		yamlContent = sections[0]
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return nil, err
 	}
 
 	var bufYaml BufYaml
-	if err := yaml.Unmarshal([]byte(yamlContent), &bufYaml); err != nil {
+	if err := doc.decode(&bufYaml); err != nil {
 		return nil, errors.Errorf("unmarshalling buf.yaml: %w", err)
 	}
 
@@ -135,7 +250,8 @@ func (r *FileReader) ReadBufYaml(ctx context.Context, path string) (*BufYaml, er
 	return readBufYamlWithMultiDoc(content)
 }
 
-// WriteBufYaml writes the buf.yaml file preserving the multi-document format
+// WriteBufYaml writes the modules of bufYaml to the buf.yaml file. Only the modules section is
+// rewritten; everything else in the file, including comments and other documents, is preserved.
 func (r *FileReader) WriteBufYaml(ctx context.Context, path string, bufYaml *BufYaml) error {
 	log := zerolog.Ctx(ctx)
 	log.Info().Str("path", path).Msg("writing buf.yaml")
@@ -146,70 +262,189 @@ func (r *FileReader) WriteBufYaml(ctx context.Context, path string, bufYaml *Buf
 		return errors.Errorf("reading buf.yaml: %w", err)
 	}
 
-	// Split by "---" to find the sections
-	sections := strings.Split(string(content), "---")
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return err
+	}
+
+	existing, err := doc.modules()
+	if err != nil {
+		return errors.Errorf("reading modules: %w", err)
+	}
 
-	// Marshal the updated YAML
-	yamlContent, err := yaml.Marshal(bufYaml)
+	modules, err := doc.modulesNode(matchModules(existing, bufYaml.Modules))
 	if err != nil {
-		return errors.Errorf("marshalling buf.yaml: %w", err)
+		return errors.Errorf("building modules: %w", err)
 	}
 
-	var outputContent string
-	if len(sections) < 2 {
-		// No sections found, just write the YAML
-		outputContent = string(yamlContent)
-	} else {
-		// Preserve the first section and update the second
-		outputContent = sections[0] + "---\n" + string(yamlContent)
+	outputContent, err := doc.set("modules", modules)
+	if err != nil {
+		return errors.Errorf("updating modules: %w", err)
 	}
 
-	if err := os.WriteFile(path, []byte(outputContent), 0644); err != nil {
+	if err := os.WriteFile(path, outputContent, 0644); err != nil {
 		return errors.Errorf("writing buf.yaml: %w", err)
 	}
 
 	return nil
 }
 
-// EnsureModulesInBufYaml ensures that all dependencies are properly referenced in the buf.yaml modules section
+// EnsureModulesInBufYaml reconciles the buf3pd-managed modules in the buf.yaml modules section with
+// the dependencies: missing modules are added, changed ones updated in place and modules of removed
+// dependencies dropped. Hand-written modules are left untouched. In a v1 workspace the module
+// directories are registered in buf.work.yaml instead.
 func (r *FileReader) EnsureModulesInBufYaml(ctx context.Context, path string, outputPath string, deps []Buf3pdDep) error {
 	log := zerolog.Ctx(ctx)
 	log.Info().Str("path", path).Msg("ensuring modules in buf.yaml")
 
-	// Read the current buf.yaml
-	bufYaml, err := r.ReadBufYaml(ctx, path)
+	version, err := WorkspaceVersion(path)
+	if err != nil {
+		return err
+	}
+
+	if version == WorkspaceV1 {
+		workPath := filepath.Join(filepath.Dir(path), BufWorkFileName)
+		plan, err := planBufWork(workPath, outputPath, deps)
+		if err != nil {
+			return err
+		}
+		return plan.apply(ctx, workPath)
+	}
+
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return errors.Errorf("reading buf.yaml: %w", err)
 	}
 
-	// Create a map of existing modules for quick lookup
-	moduleMap := make(map[string]bool)
-	for _, module := range bufYaml.Modules {
-		moduleMap[module.Name] = true
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return err
+	}
+
+	existing, err := doc.modules()
+	if err != nil {
+		return errors.Errorf("reading modules: %w", err)
 	}
 
-	// Add modules for each dependency if they don't already exist
-	updated := false
+	entries, changes := reconcileModules(existing, outputPath, deps)
+
+	// Only write the file if we made changes
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, change := range changes {
+		log.Info().Str("name", change.Module.Name).Str("path", change.Module.Path).Msgf("buf.yaml module %s", change.Action)
+	}
+
+	modules, err := doc.modulesNode(entries)
+	if err != nil {
+		return errors.Errorf("building modules: %w", err)
+	}
+
+	outputContent, err := doc.set("modules", modules)
+	if err != nil {
+		return errors.Errorf("updating modules: %w", err)
+	}
+
+	if err := os.WriteFile(path, outputContent, 0644); err != nil {
+		return errors.Errorf("writing buf.yaml: %w", err)
+	}
+
+	return nil
+}
+
+// PlanModulesInBufYaml returns the module changes EnsureModulesInBufYaml would make, without writing anything
+func (r *FileReader) PlanModulesInBufYaml(ctx context.Context, path string, outputPath string, deps []Buf3pdDep) ([]ModuleChange, error) {
+	version, err := WorkspaceVersion(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == WorkspaceV1 {
+		plan, err := planBufWork(filepath.Join(filepath.Dir(path), BufWorkFileName), outputPath, deps)
+		if err != nil {
+			return nil, err
+		}
+		return plan.changes, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	doc, err := parseBufYamlDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := doc.modules()
+	if err != nil {
+		return nil, errors.Errorf("reading modules: %w", err)
+	}
+
+	_, changes := reconcileModules(existing, outputPath, deps)
+	return changes, nil
+}
+
+// reconcileModules returns the modules section entries matching the dependencies along with the
+// changes made to the buf3pd-managed entries. A hand-written module with a dependency's name takes
+// the place of that dependency's module and is kept as is.
+func reconcileModules(existing []*bufYamlModule, outputPath string, deps []Buf3pdDep) ([]*bufYamlModule, []ModuleChange) {
+	desired := make(map[string]BufModule)
+	var names []string
 	for _, dep := range deps {
-		moduleName := dep.Repo
-		modulePath := filepath.Join(outputPath, filepath.Base(dep.Repo))
-
-		if !moduleMap[moduleName] {
-			bufYaml.Modules = append(bufYaml.Modules, BufModule{
-				Name: moduleName,
-				Path: modulePath,
-			})
-			moduleMap[moduleName] = true
-			updated = true
-			log.Info().Str("name", moduleName).Str("path", modulePath).Msg("added module to buf.yaml")
+		module := BufModuleFor(outputPath, dep)
+		if _, ok := desired[module.Name]; !ok {
+			desired[module.Name] = module
+			names = append(names, module.Name)
 		}
 	}
 
-	// Only write the file if we made changes
-	if updated {
-		if err := r.WriteBufYaml(ctx, path, bufYaml); err != nil {
-			return errors.Errorf("writing buf.yaml: %w", err)
+	entries := []*bufYamlModule{}
+	changes := []ModuleChange{}
+	for _, entry := range existing {
+		module, ok := desired[entry.module.Name]
+		switch {
+		case !entry.managed && !ok:
+			// Hand-written module unrelated to any dependency
+		case !entry.managed:
+			delete(desired, entry.module.Name)
+		case !ok:
+			changes = append(changes, ModuleChange{Action: ModuleRemoved, Module: entry.module})
+			continue
+		default:
+			delete(desired, entry.module.Name)
+			if !reflect.DeepEqual(entry.module, module) {
+				changes = append(changes, ModuleChange{Action: ModuleUpdated, Module: module})
+				entry = &bufYamlModule{module: module, managed: true, headComment: entry.headComment}
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, name := range names {
+		module, ok := desired[name]
+		if !ok {
+			continue
 		}
+		changes = append(changes, ModuleChange{Action: ModuleAdded, Module: module})
+		entries = append(entries, &bufYamlModule{module: module, managed: true})
+	}
+
+	return entries, changes
+}
+
+// WriteConfig writes a standalone buf3pd config file
+func WriteConfig(path string, cfg *Config) error {
+	content, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Errorf("marshalling buf3pd config: %w", err)
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return errors.Errorf("writing buf3pd config: %w", err)
 	}
 
 	return nil
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -240,3 +242,254 @@ func TestValidatePath(t *testing.T) {
 	_, err = os.Stat(testPath)
 	assert.NoError(t, err)
 }
+
+func TestEnsureModulesInBufYamlModuleConfig(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	tempDir := t.TempDir()
+
+	// Config-level module settings apply to every dep unless the dep overrides them
+	testBuf3pdYaml := `path: gen/buf3pd
+module:
+  lint:
+    except: [ALL]
+  breaking:
+    ignore: [google]
+deps:
+  - type: git
+    repo: github.com/example/repo1
+    path: proto
+    ref: main
+  - type: git
+    repo: github.com/example/repo2
+    path: proto
+    ref: main
+    module:
+      excludes:
+        - internal
+      lint:
+        use: [MINIMAL]
+        ignore_only:
+          FIELD_LOWER_SNAKE_CASE: [legacy/v1]
+`
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "buf.3pd.yaml"), []byte(testBuf3pdYaml), 0644))
+
+	bufYamlPath := filepath.Join(tempDir, "buf.yaml")
+	require.NoError(t, os.WriteFile(bufYamlPath, []byte("version: v2\n"), 0644))
+
+	reader := NewFileReader()
+	config, err := reader.ReadConfig(ctx, tempDir, bufYamlPath)
+	require.NoError(t, err)
+
+	require.NoError(t, reader.EnsureModulesInBufYaml(ctx, bufYamlPath, config.Path, config.Deps))
+
+	bufYaml, err := reader.ReadBufYaml(ctx, bufYamlPath)
+	require.NoError(t, err)
+	require.Len(t, bufYaml.Modules, 2)
+
+	assert.Equal(t, "gen/buf3pd/repo1", bufYaml.Modules[0].Path)
+	assert.Empty(t, bufYaml.Modules[0].Excludes)
+	assert.Equal(t, map[string]interface{}{"except": []interface{}{"ALL"}}, bufYaml.Modules[0].Lint)
+	// Ignored paths are relative to the vendored module in the config and to buf.yaml in a v2 module
+	assert.Equal(t, map[string]interface{}{"ignore": []interface{}{"gen/buf3pd/repo1/google"}}, bufYaml.Modules[0].Breaking)
+
+	assert.Equal(t, "gen/buf3pd/repo2", bufYaml.Modules[1].Path)
+	assert.Equal(t, []string{"gen/buf3pd/repo2/internal"}, bufYaml.Modules[1].Excludes)
+	assert.Equal(t, map[string]interface{}{
+		"use":         []interface{}{"MINIMAL"},
+		"ignore_only": map[string]interface{}{"FIELD_LOWER_SNAKE_CASE": []interface{}{"gen/buf3pd/repo2/legacy/v1"}},
+	}, bufYaml.Modules[1].Lint)
+	assert.Equal(t, map[string]interface{}{"ignore": []interface{}{"gen/buf3pd/repo2/google"}}, bufYaml.Modules[1].Breaking)
+}
+
+func TestReadConfigValidation(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	tests := []struct {
+		name   string
+		config string
+		errors []string
+	}{
+		{
+			name: "unknown fields",
+			config: `path: gen/buf3pd
+deps:
+  - type: git
+    repo: github.com/example/repo
+    reff: main
+    filters:
+      - "**/*.proto"
+`,
+			errors: []string{
+				`:5:5: unknown field "reff" in deps[0]`,
+				`:6:5: unknown field "filters" in deps[0]`,
+			},
+		},
+		{
+			name: "missing fields",
+			config: `path: gen/buf3pd
+deps:
+  - repo: github.com/example/repo
+`,
+			errors: []string{
+				`:3:5: deps[0]: missing required field "type"`,
+				`:3:5: deps[0]: missing required field "ref"`,
+			},
+		},
+		{
+			name: "shared path",
+			config: `path: /abs/third_party
+shared: true
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+`,
+			errors: []string{
+				`:1:7: path: path "/abs/third_party" must be relative`,
+			},
+		},
+		{
+			name: "collision winners",
+			config: `path: gen/buf3pd
+collisions:
+  winners:
+    google/api/*.proto: github.com/googleapis/googleapis
+    "[unclosed": github.com/example/repo
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+`,
+			errors: []string{
+				`:4:25: collisions.winners: winner of "google/api/*.proto" is "github.com/googleapis/googleapis", which is not a configured repo`,
+				`:5:5: collisions.winners: invalid glob "[unclosed"`,
+			},
+		},
+		{
+			name: "policy",
+			config: `path: gen/buf3pd
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+    policy:
+      licenses:
+        - Apache-2.0
+        - Proprietary
+      max_file_size: -1
+      go_package: "example.com/("
+`,
+			errors: []string{
+				`:9:11: deps[0].policy.licenses[1]: unknown SPDX license "Proprietary"`,
+				`:10:22: deps[0].policy.max_file_size: must not be negative`,
+				":11:19: deps[0].policy.go_package: invalid pattern: error parsing regexp: missing closing ): `example.com/(`",
+			},
+		},
+		{
+			name: "semantics",
+			config: `path: ../outside
+deps:
+  - type: git
+    repo: github.com/example/repo
+    path: /abs
+    ref: main
+    filter:
+      - "[unclosed"
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+  - type: git
+    repo: github.com/other/repo
+    ref: main
+    module:
+      excludes:
+        - ../../escape
+bsr_deps: delete
+`,
+			errors: []string{
+				`:1:7: path: path "../outside" must not escape its directory`,
+				`:18:11: unknown bsr_deps mode "delete": expected warn, remove or ignore`,
+				`:5:11: deps[0].path: path "/abs" must be relative`,
+				`:8:9: deps[0].filter[0]: invalid glob "[unclosed"`,
+				`:10:11: deps[1]: duplicate repo "github.com/example/repo", also at line 4`,
+				`:13:11: deps[2]: repo "github.com/other/repo" is vendored into the same directory "repo" as the repo at line 4`,
+				`:17:11: deps[2].module.excludes[0]: path "../../escape" must not escape its directory`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			configPath := filepath.Join(tempDir, ConfigFileName)
+			require.NoError(t, os.WriteFile(configPath, []byte(tt.config), 0644))
+
+			reader := NewFileReader()
+			_, err := reader.ReadConfig(ctx, tempDir, filepath.Join(tempDir, "buf.yaml"))
+			require.Error(t, err)
+
+			var validationErrors ValidationErrors
+			require.ErrorAs(t, err, &validationErrors)
+			require.Len(t, validationErrors, len(tt.errors))
+			for i, expected := range tt.errors {
+				assert.Equal(t, configPath+expected, validationErrors[i].Error())
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema()
+	assert.Equal(t, SchemaID, schema["$schema"])
+	assert.Equal(t, []string{"deps"}, schema["required"])
+
+	deps := schema["properties"].(map[string]any)["deps"].(map[string]any)
+	dep := deps["items"].(map[string]any)
+	assert.Equal(t, false, dep["additionalProperties"])
+	assert.Equal(t, []string{"type", "repo", "ref"}, dep["required"])
+	assert.Contains(t, dep["properties"], "filter")
+}
+
+func TestJSONSchemaUpToDate(t *testing.T) {
+	// The committed schema is referenced from yaml-language-server headers and must match the config types
+	schemaPath := filepath.Join("..", "..", "buf3pd.schema.json")
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	require.NoError(t, encoder.Encode(JSONSchema()))
+
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(schemaPath, buf.Bytes(), 0644))
+	}
+
+	expected, err := os.ReadFile(schemaPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String(), "run go test ./pkg/config -update to regenerate buf3pd.schema.json")
+}
+
+func TestReadConfigSharedPath(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ConfigFileName), []byte(`path: ../../third_party
+shared: true
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+`), 0644))
+
+	// A shared vendor directory may lie outside the working directory
+	config, err := NewFileReader().ReadConfig(ctx, tempDir, filepath.Join(tempDir, "buf.yaml"))
+	require.NoError(t, err)
+	assert.True(t, config.Shared)
+	assert.Equal(t, "../../third_party", config.Path)
+}
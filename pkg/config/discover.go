@@ -0,0 +1,183 @@
+package config
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the names of standalone buf3pd config files. JSON is read as YAML, of which it
+// is a subset, so both keep line and column information for errors.
+var ConfigFileNames = []string{
+	"buf.3pd.yaml",
+	"buf3pd.yaml",
+	"buf.3pd.yml",
+	"buf3pd.yml",
+	"buf.3pd.json",
+	"buf3pd.json",
+	"buf.3pd.toml",
+	"buf3pd.toml",
+}
+
+// FindConfigFile returns the standalone buf3pd config file in dir, or an empty path if there is
+// none. More than one config file in the same directory is ambiguous and an error.
+func FindConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			found = append(found, path)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	default:
+		return "", errors.Errorf("ambiguous buf3pd config: found %s", strings.Join(found, ", "))
+	}
+}
+
+// FindConfigFiles returns every standalone buf3pd config file below root, in lexical order of their
+// directories. Hidden directories, such as .git, are not searched.
+func FindConfigFiles(root string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		configPath, err := FindConfigFile(path)
+		if err != nil {
+			return err
+		}
+		if configPath != "" {
+			found = append(found, configPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Errorf("finding buf3pd configs in %s: %w", root, err)
+	}
+
+	return found, nil
+}
+
+// ReadConfig discovers and reads the buf3pd configuration. A standalone config file in workDir wins,
+// then the buf3pd section of the buf.yaml at configPath, then the nearest standalone config file in
+// a parent of workDir. The search stops at the root of the git repository holding workDir. A
+// standalone config file in workDir next to a buf3pd section in buf.yaml is ambiguous and an error.
+func (r *FileReader) ReadConfig(ctx context.Context, workDir string, configPath string) (*Config, error) {
+	log := zerolog.Ctx(ctx)
+
+	path, err := FindConfigFile(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var section *yaml.Node
+	content, err := os.ReadFile(configPath)
+	switch {
+	case err == nil:
+		doc, err := parseBufYamlDocument(content)
+		if err != nil {
+			return nil, errors.Errorf("reading buf.yaml: %w", err)
+		}
+		_, section = doc.get("buf3pd")
+	case !os.IsNotExist(err):
+		return nil, errors.Errorf("reading buf.yaml: %w", err)
+	}
+
+	switch {
+	case path != "" && section != nil:
+		return nil, errors.Errorf("ambiguous buf3pd config: found %s and a buf3pd section in %s", path, configPath)
+	case path != "":
+		log.Info().Str("path", path).Str("reason", "found in working directory").Msg("using buf3pd config")
+		return r.ReadConfigFile(ctx, path)
+	case section != nil:
+		log.Info().Str("path", configPath).Str("reason", "buf3pd section of buf.yaml").Msg("using buf3pd config")
+		config, err := decodeConfig(section, configPath)
+		if err != nil {
+			return nil, errors.Errorf("invalid buf3pd config: %w", err)
+		}
+		return config, nil
+	}
+
+	dir := workDir
+	for !isRepositoryRoot(dir) {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+
+		path, err := FindConfigFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			log.Info().Str("path", path).Str("reason", "found in parent directory").Msg("using buf3pd config")
+			return r.ReadConfigFile(ctx, path)
+		}
+	}
+
+	return nil, errors.Errorf("no buf3pd config found: looked for %s in %s and its parents, and for a buf3pd section in %s",
+		strings.Join(ConfigFileNames, ", "), workDir, configPath)
+}
+
+// isRepositoryRoot reports whether dir is the root of a git repository
+func isRepositoryRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// ReadConfigFile reads a standalone buf3pd config file, in YAML, JSON or TOML depending on its extension
+func (r *FileReader) ReadConfigFile(ctx context.Context, path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("reading buf3pd config: %w", err)
+	}
+
+	var node yaml.Node
+	switch filepath.Ext(path) {
+	case ".toml":
+		// TOML is converted to a YAML node so it is decoded and validated like the other formats. The
+		// converted node has no positions, so errors in TOML configs carry no line and column.
+		var raw map[string]any
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return nil, errors.Errorf("unmarshalling %s: %w", path, err)
+		}
+		if err := node.Encode(raw); err != nil {
+			return nil, errors.Errorf("converting %s: %w", path, err)
+		}
+	default:
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, errors.Errorf("unmarshalling %s: %w", path, err)
+		}
+	}
+
+	config, err := decodeConfig(&node, path)
+	if err != nil {
+		if filepath.Ext(path) == ".toml" {
+			return nil, errors.Errorf("invalid buf3pd config (TOML configs are validated without line and column information): %w", err)
+		}
+		return nil, errors.Errorf("invalid buf3pd config: %w", err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFileFormats(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "buf.3pd.yaml",
+			content: `path: proto
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: main
+`,
+		},
+		{
+			name:    "buf3pd.json",
+			content: `{"path": "proto", "deps": [{"type": "git", "repo": "github.com/example/repo", "ref": "main"}]}`,
+		},
+		{
+			name: "buf.3pd.toml",
+			content: `path = "proto"
+
+[[deps]]
+type = "git"
+repo = "github.com/example/repo"
+ref = "main"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tempDir, tt.name), []byte(tt.content), 0644))
+
+			config, err := NewFileReader().ReadConfig(ctx, tempDir, filepath.Join(tempDir, "buf.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, "proto", config.Path)
+			require.Len(t, config.Deps, 1)
+			assert.Equal(t, "github.com/example/repo", config.Deps[0].Repo)
+			assert.Equal(t, "main", config.Deps[0].Ref)
+		})
+	}
+}
+
+func TestReadConfigFileTOMLValidation(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	path := filepath.Join(t.TempDir(), "buf3pd.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`unknown = "x"
+
+[[deps]]
+type = "git"
+repo = "github.com/example/repo"
+ref = "main"
+`), 0644))
+
+	_, err := NewFileReader().ReadConfigFile(ctx, path)
+	var validationErrors ValidationErrors
+	require.ErrorAs(t, err, &validationErrors)
+	require.Len(t, validationErrors, 1)
+	assert.Equal(t, path+`: unknown field "unknown"`, validationErrors[0].Error())
+	assert.Contains(t, err.Error(), "without line and column information")
+}
+
+func TestReadConfigDiscovery(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	configFor := func(path string) string {
+		return "path: " + path + "\ndeps:\n  - type: git\n    repo: github.com/example/repo\n    ref: main\n"
+	}
+	bufYamlFor := func(path string) string {
+		return "version: v2\nbuf3pd:\n  path: " + path + "\n  deps:\n    - type: git\n      repo: github.com/example/repo\n      ref: main\n"
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected string
+		file     string
+		err      string
+	}{
+		{
+			name: "workdir file wins over buf.yaml without a buf3pd section",
+			files: map[string]string{
+				"repo/work/buf3pd.yaml": configFor("workdir"),
+				"repo/work/buf.yaml":    "version: v2\n",
+			},
+			expected: "workdir",
+			file:     "repo/work/buf3pd.yaml",
+		},
+		{
+			name: "workdir file and buf.yaml section",
+			files: map[string]string{
+				"repo/work/buf3pd.yaml": configFor("workdir"),
+				"repo/work/buf.yaml":    bufYamlFor("bufyaml"),
+			},
+			err: "ambiguous buf3pd config",
+		},
+		{
+			name: "buf.yaml wins over parent file",
+			files: map[string]string{
+				"repo/buf.3pd.yaml":  configFor("parent"),
+				"repo/work/buf.yaml": bufYamlFor("bufyaml"),
+			},
+			expected: "bufyaml",
+			file:     "repo/work/buf.yaml",
+		},
+		{
+			name: "parent file",
+			files: map[string]string{
+				"repo/buf.3pd.json":  `{"path": "parent", "deps": [{"type": "git", "repo": "github.com/example/repo", "ref": "main"}]}`,
+				"repo/work/buf.yaml": "version: v2\n",
+			},
+			expected: "parent",
+			file:     "repo/buf.3pd.json",
+		},
+		{
+			name: "stops at repository root",
+			files: map[string]string{
+				"buf.3pd.yaml":       configFor("outside"),
+				"repo/work/buf.yaml": "version: v2\n",
+			},
+			err: "no buf3pd config found",
+		},
+		{
+			name: "ambiguous",
+			files: map[string]string{
+				"repo/work/buf.3pd.yaml": configFor("one"),
+				"repo/work/buf3pd.toml":  "path = \"two\"\n",
+			},
+			err: "ambiguous buf3pd config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "repo", ".git"), 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "repo", "work"), 0755))
+			for name, content := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+			}
+
+			workDir := filepath.Join(tempDir, "repo", "work")
+			config, err := NewFileReader().ReadConfig(ctx, workDir, filepath.Join(workDir, "buf.yaml"))
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, config.Path)
+			assert.Equal(t, filepath.Join(tempDir, tt.file), config.File)
+		})
+	}
+}
+
+func TestFindConfigFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"buf.3pd.yaml",
+		"services/a/buf3pd.yaml",
+		"services/b/buf.3pd.toml",
+		"services/b/gen/buf.yaml",
+		".git/buf.3pd.yaml",
+	} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	found, err := FindConfigFiles(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "buf.3pd.yaml"),
+		filepath.Join(root, "services/a/buf3pd.yaml"),
+		filepath.Join(root, "services/b/buf.3pd.toml"),
+	}, found)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "services/a/buf3pd.json"), nil, 0644))
+	_, err = FindConfigFiles(root)
+	assert.ErrorContains(t, err, "ambiguous buf3pd config")
+}
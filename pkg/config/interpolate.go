@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// interpolate expands ${VAR} and ${VAR:-default} references in s using lookup. The default is
+// used when the variable is unset or empty, and $${ escapes a literal ${.
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+
+		// $${ is an escaped ${
+		if start > 0 && s[start-1] == '$' {
+			out.WriteString(s[:start-1])
+			out.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", errors.Errorf("unterminated variable reference in %q", s)
+		}
+		end += start
+
+		name, def, hasDefault := strings.Cut(s[start+2:end], ":-")
+		if !validVariableName(name) {
+			return "", errors.Errorf("invalid variable name %q", name)
+		}
+
+		value, ok := lookup(name)
+		switch {
+		case hasDefault && value == "":
+			value = def
+		case !ok:
+			return "", errors.Errorf("undefined variable %q", name)
+		}
+
+		out.WriteString(s[:start])
+		out.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+// validVariableName reports whether name is a valid environment variable name
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// interpolateNode expands variable references in every scalar value below node, recording an
+// error at each value that references an undefined variable
+func (v *validator) interpolateNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := interpolate(node.Value, os.LookupEnv)
+		if err != nil {
+			v.errorf(node, "%s", err.Error())
+			return
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		// Keys are field names and are never interpolated
+		for i := 1; i < len(node.Content); i += 2 {
+			v.interpolateNode(node.Content[i])
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			v.interpolateNode(child)
+		}
+	}
+}
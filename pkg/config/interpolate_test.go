@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "gitea.internal", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{input: "github.com/org/repo", want: "github.com/org/repo"},
+		{input: "${HOST}/org/repo", want: "gitea.internal/org/repo"},
+		{input: "${MISSING:-github.com}/org/repo", want: "github.com/org/repo"},
+		{input: "${EMPTY:-heads/main}", want: "heads/main"},
+		{input: "${HOST:-github.com}", want: "gitea.internal"},
+		{input: "$${HOST}", want: "${HOST}"},
+		{input: "${MISSING}", err: `undefined variable "MISSING"`},
+		{input: "${HOST", err: `unterminated variable reference in "${HOST"`},
+		{input: "${1X}", err: `invalid variable name "1X"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := interpolate(tt.input, lookup)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadConfigInterpolation(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	t.Setenv("BUF3PD_TEST_MIRROR", "gitea.internal/mirror")
+	t.Setenv("BUF3PD_TEST_REF", "tags/v1.2.3")
+
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, ConfigFileName)
+	require.NoError(t, os.WriteFile(configPath, []byte(`path: gen/buf3pd
+deps:
+  - type: git
+    repo: ${BUF3PD_TEST_MIRROR:-github.com/example}/repo
+    path: ${BUF3PD_TEST_PATH:-proto}
+    ref: ${BUF3PD_TEST_REF}
+`), 0644))
+
+	reader := NewFileReader()
+	config, err := reader.ReadConfig(ctx, tempDir, filepath.Join(tempDir, "buf.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "gitea.internal/mirror/repo", config.Deps[0].Repo)
+	assert.Equal(t, "proto", config.Deps[0].Path)
+	assert.Equal(t, "tags/v1.2.3", config.Deps[0].Ref)
+
+	// Undefined variables are validation errors
+	require.NoError(t, os.WriteFile(configPath, []byte(`path: gen/buf3pd
+deps:
+  - type: git
+    repo: github.com/example/repo
+    ref: ${BUF3PD_TEST_UNDEFINED}
+`), 0644))
+
+	_, err = reader.ReadConfig(ctx, tempDir, filepath.Join(tempDir, "buf.yaml"))
+	var validationErrors ValidationErrors
+	require.ErrorAs(t, err, &validationErrors)
+	require.Len(t, validationErrors, 1)
+	assert.Equal(t, configPath+`:5:10: undefined variable "BUF3PD_TEST_UNDEFINED"`, validationErrors[0].Error())
+}
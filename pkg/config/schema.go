@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the JSON Schema draft the buf3pd config schema is written against
+const SchemaID = "https://json-schema.org/draft-07/schema#"
+
+// JSONSchema returns the JSON Schema of the buf3pd config file, generated from the Config type. It
+// can be referenced from a yaml-language-server header for editor completion and validation.
+func JSONSchema() map[string]any {
+	schema := schemaFor(reflect.TypeOf(Config{}))
+	schema["$schema"] = SchemaID
+	schema["title"] = "buf3pd config"
+	return schema
+}
+
+// schemaFor returns the schema of a Go type, following the yaml names of struct fields
+func schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			property := schemaFor(field.Type)
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			for _, option := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+				switch {
+				case option == "required":
+					required = append(required, name)
+				case strings.HasPrefix(option, "enum="):
+					property["enum"] = strings.Split(strings.TrimPrefix(option, "enum="), "|")
+				}
+			}
+			properties[name] = property
+		}
+
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		schema := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = schemaFor(t.Elem())
+		}
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"buf.build/go/spdx"
+	"github.com/bmatcuk/doublestar/v4"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem found in a buf3pd config, located at the node it was found on
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	// Configs converted from TOML have no positions
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors holds every problem found in a buf3pd config
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// validator collects validation errors against the nodes of a config read from file
+type validator struct {
+	file string
+	errs ValidationErrors
+}
+
+// errorf records a validation error at a node
+func (v *validator) errorf(node *yaml.Node, format string, args ...any) {
+	v.errs = append(v.errs, &ValidationError{File: v.file, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the collected errors, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// decodeConfig strictly decodes a buf3pd config read from file: unknown fields are rejected,
+// environment variables are interpolated and the decoded config is validated, with every error
+// carrying the line and column it was found at
+func decodeConfig(node *yaml.Node, file string) (*Config, error) {
+	if node.Kind == yaml.DocumentNode || node.Kind == 0 {
+		if len(node.Content) == 0 {
+			return nil, errors.Errorf("%s: config is empty", file)
+		}
+		node = node.Content[0]
+	}
+
+	v := &validator{file: file}
+	v.checkKnownFields(node, reflect.TypeOf(Config{}), "")
+	v.interpolateNode(node)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := node.Decode(&config); err != nil {
+		return nil, errors.Errorf("%s: %w", file, err)
+	}
+
+	v.validateConfig(&config, node)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	config.applyModuleDefaults()
+	config.File = file
+
+	return &config, nil
+}
+
+// checkKnownFields reports every mapping key that does not correspond to a field of t
+func (v *validator) checkKnownFields(node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.errorf(key, "unknown field %q%s", key.Value, inPath(path))
+				continue
+			}
+			v.checkKnownFields(node.Content[i+1], field.Type, joinPath(path, key.Value))
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			v.checkKnownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// yamlFields maps the YAML names of a struct's fields to the fields
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// validateConfig checks the semantics of a decoded config against its nodes
+func (v *validator) validateConfig(config *Config, node *yaml.Node) {
+	if pathNode := valueNode(node, "path"); pathNode != nil {
+		// A shared vendor directory usually sits next to the projects using it
+		if config.Shared {
+			v.checkRelativePath(pathNode, "path", config.Path)
+		} else {
+			v.checkLocalPath(pathNode, "path", config.Path)
+		}
+	}
+
+	if config.BSRDeps != "" {
+		if _, err := config.BSRDepsMode(); err != nil {
+			v.errorf(valueNode(node, "bsr_deps"), "%s", err.Error())
+		}
+	}
+
+	if config.Module != nil {
+		v.validateModule(valueNode(node, "module"), "module")
+	}
+
+	if mirrorsNode := valueNode(node, "mirrors"); mirrorsNode != nil && mirrorsNode.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(mirrorsNode.Content); i += 2 {
+			if mirrorsNode.Content[i+1].Value == "" {
+				v.errorf(mirrorsNode.Content[i+1], "mirrors: mirror of %q is empty", mirrorsNode.Content[i].Value)
+			}
+		}
+	}
+
+	if config.Collisions != nil {
+		v.validateCollisions(config, valueNode(valueNode(node, "collisions"), "winners"))
+	}
+
+	depsNode := valueNode(node, "deps")
+	if len(config.Deps) == 0 {
+		at := node
+		if depsNode != nil {
+			at = depsNode
+		}
+		v.errorf(at, "no dependencies configured")
+		return
+	}
+
+	// Dependencies are vendored into a directory named after the last element of their repo
+	repos := make(map[string]*yaml.Node)
+	dirs := make(map[string]*yaml.Node)
+	for i, dep := range config.Deps {
+		depNode := depsNode.Content[i]
+		path := fmt.Sprintf("deps[%d]", i)
+
+		for _, required := range []struct{ key, value string }{{"type", dep.Type}, {"repo", dep.Repo}, {"ref", dep.Ref}} {
+			if required.value == "" {
+				v.errorf(depNode, "%s: missing required field %q", path, required.key)
+			}
+		}
+
+		if repoNode := valueNode(depNode, "repo"); repoNode != nil && dep.Repo != "" {
+			dir := filepath.Base(dep.Repo)
+			switch {
+			case repos[dep.Repo] != nil:
+				v.errorf(repoNode, "%s: duplicate repo %q, also at line %d", path, dep.Repo, repos[dep.Repo].Line)
+			case dirs[dir] != nil:
+				v.errorf(repoNode, "%s: repo %q is vendored into the same directory %q as the repo at line %d", path, dep.Repo, dir, dirs[dir].Line)
+			default:
+				repos[dep.Repo] = repoNode
+				dirs[dir] = repoNode
+			}
+		}
+
+		if pathNode := valueNode(depNode, "path"); pathNode != nil {
+			v.checkLocalPath(pathNode, path+".path", dep.Path)
+		}
+
+		if filterNode := valueNode(depNode, "filter"); filterNode != nil {
+			for j, filter := range dep.Filter {
+				if !doublestar.ValidatePattern(filter) {
+					v.errorf(filterNode.Content[j], "%s.filter[%d]: invalid glob %q", path, j, filter)
+				}
+			}
+		}
+
+		if dep.Module != nil {
+			v.validateModule(valueNode(depNode, "module"), path+".module")
+		}
+
+		if dep.Policy != nil {
+			v.validatePolicy(dep.Policy, valueNode(depNode, "policy"), path+".policy")
+		}
+	}
+}
+
+// validateCollisions checks that winners are valid globs naming a configured repo
+func (v *validator) validateCollisions(config *Config, winnersNode *yaml.Node) {
+	if winnersNode == nil || winnersNode.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(winnersNode.Content); i += 2 {
+		pattern, repo := winnersNode.Content[i], winnersNode.Content[i+1]
+		if !doublestar.ValidatePattern(pattern.Value) {
+			v.errorf(pattern, "collisions.winners: invalid glob %q", pattern.Value)
+		}
+		if !slices.ContainsFunc(config.Deps, func(dep Buf3pdDep) bool { return dep.Repo == repo.Value }) {
+			v.errorf(repo, "collisions.winners: winner of %q is %q, which is not a configured repo", pattern.Value, repo.Value)
+		}
+	}
+}
+
+// validatePolicy checks that policy licenses are SPDX identifiers and its patterns compile
+func (v *validator) validatePolicy(policy *PolicyConfig, node *yaml.Node, path string) {
+	if licensesNode := valueNode(node, "licenses"); licensesNode != nil && licensesNode.Kind == yaml.SequenceNode {
+		for j, item := range licensesNode.Content {
+			if _, ok := spdx.LicenseForID(item.Value); !ok {
+				v.errorf(item, "%s.licenses[%d]: unknown SPDX license %q", path, j, item.Value)
+			}
+		}
+	}
+
+	if policy.MaxFileSize < 0 {
+		v.errorf(valueNode(node, "max_file_size"), "%s.max_file_size: must not be negative", path)
+	}
+
+	if policy.GoPackage != "" {
+		if _, err := regexp.Compile(policy.GoPackage); err != nil {
+			v.errorf(valueNode(node, "go_package"), "%s.go_package: invalid pattern: %s", path, err.Error())
+		}
+	}
+}
+
+// validateModule checks that module includes and excludes stay within the vendored module
+func (v *validator) validateModule(node *yaml.Node, path string) {
+	for _, key := range []string{"includes", "excludes"} {
+		listNode := valueNode(node, key)
+		if listNode == nil || listNode.Kind != yaml.SequenceNode {
+			continue
+		}
+		for j, item := range listNode.Content {
+			v.checkLocalPath(item, fmt.Sprintf("%s.%s[%d]", path, key, j), item.Value)
+		}
+	}
+}
+
+// checkLocalPath reports paths that are absolute or escape their base directory with ".."
+func (v *validator) checkLocalPath(node *yaml.Node, path string, value string) {
+	if !v.checkRelativePath(node, path, value) {
+		return
+	}
+	if value != "" && value != "." && !filepath.IsLocal(value) {
+		v.errorf(node, "%s: path %q must not escape its directory", path, value)
+	}
+}
+
+// checkRelativePath reports absolute paths, returning whether the path is relative
+func (v *validator) checkRelativePath(node *yaml.Node, path string, value string) bool {
+	if filepath.IsAbs(value) || strings.HasPrefix(value, "/") {
+		v.errorf(node, "%s: path %q must be relative", path, value)
+		return false
+	}
+	return true
+}
+
+// valueNode returns the value of a key in a mapping node, or nil if it is not present
+func valueNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// joinPath appends a field to a dotted field path
+func joinPath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// inPath describes where a field was found for error messages
+func inPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " in " + path
+}
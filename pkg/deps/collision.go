@@ -0,0 +1,289 @@
+package deps
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Collision resolutions
+const (
+	CollisionWinner = "winner"
+	CollisionDedupe = "dedupe"
+)
+
+// Collision is a file path provided by several dependencies
+type Collision struct {
+	Path string `json:"path"`
+	// Repos are the dependencies providing the file, in config order
+	Repos     []string `json:"repos"`
+	Identical bool     `json:"identical"`
+	// Resolution is how the collision was resolved, winner or dedupe
+	Resolution string `json:"resolution"`
+	// Kept is the dependency whose copy of the file is vendored
+	Kept string `json:"kept"`
+}
+
+// CollisionError reports the collisions no winner or deduplication resolves
+type CollisionError struct {
+	Collisions []*Collision
+}
+
+func (e *CollisionError) Error() string {
+	lines := make([]string, 0, len(e.Collisions))
+	for _, collision := range e.Collisions {
+		content := "different content"
+		if collision.Identical {
+			content = "identical content"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: provided by %s (%s)", collision.Path, strings.Join(collision.Repos, ", "), content))
+	}
+	return fmt.Sprintf("files provided by several dependencies, choose a winner under collisions.winners or set collisions.dedupe for identical copies:\n%s", strings.Join(lines, "\n"))
+}
+
+// findCollisions returns the proto files several of the dependency file sets provide, sorted by path
+func findCollisions(depFiles []*DepFiles) []*Collision {
+	type provider struct {
+		repo    string
+		content []byte
+	}
+	providers := map[string][]provider{}
+	for _, d := range depFiles {
+		for _, f := range d.Files {
+			if !strings.HasSuffix(f.Path, ".proto") {
+				continue
+			}
+			providers[f.Path] = append(providers[f.Path], provider{repo: d.DepInfo.Repo, content: f.Content})
+		}
+	}
+
+	collisions := []*Collision{}
+	for path, provided := range providers {
+		if len(provided) < 2 {
+			continue
+		}
+		collision := &Collision{Path: path, Identical: true}
+		for _, p := range provided {
+			collision.Repos = append(collision.Repos, p.repo)
+			if !bytes.Equal(p.content, provided[0].content) {
+				collision.Identical = false
+			}
+		}
+		collisions = append(collisions, collision)
+	}
+	slices.SortFunc(collisions, func(a, b *Collision) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return collisions
+}
+
+// resolveCollision picks the dependency whose copy of a colliding file is kept: the configured
+// winner of the first pattern matching the path, or the first provider when identical copies are
+// deduplicated. It leaves the collision unresolved otherwise.
+func resolveCollision(collision *Collision, cfg *config.CollisionsConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	patterns := make([]string, 0, len(cfg.Winners))
+	for pattern := range cfg.Winners {
+		patterns = append(patterns, pattern)
+	}
+	slices.Sort(patterns)
+
+	for _, pattern := range patterns {
+		matched, err := doublestar.Match(pattern, collision.Path)
+		if err != nil {
+			return errors.Errorf("matching collisions.winners pattern %q: %w", pattern, err)
+		}
+		if !matched {
+			continue
+		}
+		winner := cfg.Winners[pattern]
+		if !slices.Contains(collision.Repos, winner) {
+			return errors.Errorf("%s: winner %s does not provide the file, it is provided by %s", collision.Path, winner, strings.Join(collision.Repos, ", "))
+		}
+		collision.Resolution = CollisionWinner
+		collision.Kept = winner
+		return nil
+	}
+
+	if cfg.Dedupe && collision.Identical {
+		collision.Resolution = CollisionDedupe
+		collision.Kept = collision.Repos[0]
+	}
+
+	return nil
+}
+
+// dropCollidingFiles removes the copies of resolved colliding files from the dependencies that lost
+// them, returning the indexes of the file sets it changed
+func dropCollidingFiles(depFiles []*DepFiles, collisions []*Collision) []int {
+	dropped := map[string]map[string]bool{}
+	for _, collision := range collisions {
+		for _, repo := range collision.Repos {
+			if repo == collision.Kept {
+				continue
+			}
+			if dropped[repo] == nil {
+				dropped[repo] = map[string]bool{}
+			}
+			dropped[repo][collision.Path] = true
+		}
+	}
+
+	changed := []int{}
+	for i, d := range depFiles {
+		paths := dropped[d.DepInfo.Repo]
+		if len(paths) == 0 {
+			continue
+		}
+		// Build a new slice, since the files may also be the dependency's previous files
+		kept := make([]*file.File, 0, len(d.Files))
+		for _, f := range d.Files {
+			if !paths[f.Path] {
+				kept = append(kept, f)
+			}
+		}
+		d.Files = kept
+		changed = append(changed, i)
+	}
+
+	return changed
+}
+
+// resolveCollisions resolves the files several planned dependencies provide, dropping the losing
+// copies and updating the lock entries and results of the dependencies that lost files. It fails
+// with a CollisionError when a collision is left unresolved.
+func (m *DependencyManager) resolveCollisions(plan *Plan, cfg *config.CollisionsConfig) error {
+	collisions := findCollisions(plan.depFiles)
+
+	unresolved := []*Collision{}
+	for _, collision := range collisions {
+		if err := resolveCollision(collision, cfg); err != nil {
+			return err
+		}
+		if collision.Resolution == "" {
+			unresolved = append(unresolved, collision)
+		}
+	}
+	if len(unresolved) > 0 {
+		return &CollisionError{Collisions: unresolved}
+	}
+
+	for _, i := range dropCollidingFiles(plan.depFiles, collisions) {
+		previous := plan.Results[i]
+
+		// A dependency left without files would be vendored as an empty module
+		if len(plan.depFiles[i].Files) == 0 {
+			repo := plan.depFiles[i].DepInfo.Repo
+			return errors.Errorf("%s: every file is also provided by %s and was dropped, remove the dependency or change collisions.winners", repo, strings.Join(keptFor(collisions, repo), ", "))
+		}
+
+		lockDep, err := plan.depFiles[i].LockEntry(m.fileHandler)
+		if err != nil {
+			return errors.Errorf("creating lock entry: %w", err)
+		}
+		lockDep.Dropped = droppedFiles(collisions, lockDep.Repo)
+		for j, planned := range plan.LockDeps {
+			if planned.Repo == lockDep.Repo {
+				lockDep.Metadata = planned.Metadata
+				plan.LockDeps[j] = lockDep
+			}
+		}
+
+		result := NewResult(lockDep, previous.Origin, plan.previousFiles[i], plan.depFiles[i].Files)
+		result.PreviousCommit = previous.PreviousCommit
+		plan.Results[i] = result
+	}
+
+	plan.Collisions = collisions
+
+	return nil
+}
+
+// droppedFiles returns the resolved colliding files repo was vendored without
+func droppedFiles(collisions []*Collision, repo string) []*lock.DroppedFile {
+	var dropped []*lock.DroppedFile
+	for _, collision := range collisions {
+		if collision.Kept == repo || !slices.Contains(collision.Repos, repo) {
+			continue
+		}
+		dropped = append(dropped, &lock.DroppedFile{Path: collision.Path, Kept: collision.Kept, Resolution: collision.Resolution})
+	}
+	return dropped
+}
+
+// keptFor returns the sorted dependencies whose copies of the files repo lost are kept
+func keptFor(collisions []*Collision, repo string) []string {
+	kept := []string{}
+	for _, d := range droppedFiles(collisions, repo) {
+		if !slices.Contains(kept, d.Kept) {
+			kept = append(kept, d.Kept)
+		}
+	}
+	slices.Sort(kept)
+	return kept
+}
+
+// staleDropped returns the indexes of the local dependencies that were vendored without colliding
+// files which would now be resolved differently: the dependency that kept a file is gone, no longer
+// provides it or is fetched again, or the collisions config picks another copy
+func staleDropped(plan *Plan, cfg *config.CollisionsConfig) []int {
+	index := make(map[string]int, len(plan.Results))
+	for i, result := range plan.Results {
+		index[result.Repo] = i
+	}
+
+	stale := []int{}
+	for i, result := range plan.Results {
+		if result.Origin == OriginRemote {
+			continue
+		}
+		for _, lockDep := range plan.LockDeps {
+			if lockDep.Repo == result.Repo && droppedChanged(plan, index, i, lockDep.Dropped, cfg) {
+				stale = append(stale, i)
+				break
+			}
+		}
+	}
+
+	return stale
+}
+
+// droppedChanged reports whether any of the files the i-th result was vendored without would now be
+// resolved differently
+func droppedChanged(plan *Plan, index map[string]int, i int, dropped []*lock.DroppedFile, cfg *config.CollisionsConfig) bool {
+	for _, d := range dropped {
+		k, ok := index[d.Kept]
+		if !ok || plan.Results[k].Origin == OriginRemote {
+			return true
+		}
+		provided := slices.ContainsFunc(plan.depFiles[k].Files, func(f *file.File) bool {
+			return f.Path == d.Path
+		})
+		if !provided {
+			return true
+		}
+
+		// Resolve the collision again between the two dependencies, in config order
+		collision := &Collision{Path: d.Path, Identical: d.Resolution == CollisionDedupe}
+		if k < i {
+			collision.Repos = []string{d.Kept, plan.Results[i].Repo}
+		} else {
+			collision.Repos = []string{plan.Results[i].Repo, d.Kept}
+		}
+		if err := resolveCollision(collision, cfg); err != nil || collision.Kept != d.Kept || collision.Resolution != d.Resolution {
+			return true
+		}
+	}
+	return false
+}
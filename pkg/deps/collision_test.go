@@ -0,0 +1,273 @@
+package deps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+)
+
+func collidingDeps() []*DepFiles {
+	return []*DepFiles{
+		{
+			DepInfo: config.Buf3pdDep{Repo: "github.com/googleapis/googleapis"},
+			Files: []*file.File{
+				{Path: "google/api/annotations.proto", Content: []byte("upstream")},
+				{Path: "google/api/http.proto", Content: []byte("http")},
+			},
+		},
+		{
+			DepInfo: config.Buf3pdDep{Repo: "github.com/example/fork"},
+			Files: []*file.File{
+				{Path: "example/v1/service.proto", Content: []byte("service")},
+				{Path: "google/api/annotations.proto", Content: []byte("fork")},
+				{Path: "google/api/http.proto", Content: []byte("http")},
+				{Path: "README.md", Content: []byte("readme")},
+			},
+		},
+	}
+}
+
+func TestFindCollisions(t *testing.T) {
+	deps := collidingDeps()
+	deps[0].Files = append(deps[0].Files, &file.File{Path: "README.md", Content: []byte("readme")})
+
+	collisions := findCollisions(deps)
+	require.Len(t, collisions, 2)
+
+	assert.Equal(t, "google/api/annotations.proto", collisions[0].Path)
+	assert.Equal(t, []string{"github.com/googleapis/googleapis", "github.com/example/fork"}, collisions[0].Repos)
+	assert.False(t, collisions[0].Identical)
+
+	assert.Equal(t, "google/api/http.proto", collisions[1].Path)
+	assert.True(t, collisions[1].Identical)
+}
+
+func TestResolveCollision(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config.CollisionsConfig
+		path       string
+		identical  bool
+		resolution string
+		kept       string
+		err        string
+	}{
+		{
+			name:      "unconfigured",
+			path:      "google/api/http.proto",
+			identical: true,
+		},
+		{
+			name:       "winner by glob",
+			cfg:        &config.CollisionsConfig{Winners: map[string]string{"google/api/*.proto": "github.com/example/fork"}},
+			path:       "google/api/annotations.proto",
+			resolution: CollisionWinner,
+			kept:       "github.com/example/fork",
+		},
+		{
+			name: "winner before dedupe",
+			cfg: &config.CollisionsConfig{
+				Dedupe:  true,
+				Winners: map[string]string{"google/api/http.proto": "github.com/example/fork"},
+			},
+			path:       "google/api/http.proto",
+			identical:  true,
+			resolution: CollisionWinner,
+			kept:       "github.com/example/fork",
+		},
+		{
+			name:       "dedupe identical",
+			cfg:        &config.CollisionsConfig{Dedupe: true},
+			path:       "google/api/http.proto",
+			identical:  true,
+			resolution: CollisionDedupe,
+			kept:       "github.com/googleapis/googleapis",
+		},
+		{
+			name: "dedupe leaves different content",
+			cfg:  &config.CollisionsConfig{Dedupe: true},
+			path: "google/api/annotations.proto",
+		},
+		{
+			name: "winner not providing the file",
+			cfg:  &config.CollisionsConfig{Winners: map[string]string{"google/**": "github.com/other/repo"}},
+			path: "google/api/annotations.proto",
+			err:  "google/api/annotations.proto: winner github.com/other/repo does not provide the file, it is provided by github.com/googleapis/googleapis, github.com/example/fork",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collision := &Collision{
+				Path:      tt.path,
+				Repos:     []string{"github.com/googleapis/googleapis", "github.com/example/fork"},
+				Identical: tt.identical,
+			}
+
+			err := resolveCollision(collision, tt.cfg)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.resolution, collision.Resolution)
+			assert.Equal(t, tt.kept, collision.Kept)
+		})
+	}
+}
+
+func TestDropCollidingFiles(t *testing.T) {
+	deps := collidingDeps()
+	previous := deps[1].Files
+
+	changed := dropCollidingFiles(deps, []*Collision{
+		{Path: "google/api/annotations.proto", Repos: []string{"github.com/googleapis/googleapis", "github.com/example/fork"}, Kept: "github.com/googleapis/googleapis"},
+		{Path: "google/api/http.proto", Repos: []string{"github.com/googleapis/googleapis", "github.com/example/fork"}, Kept: "github.com/googleapis/googleapis"},
+	})
+	assert.Equal(t, []int{1}, changed)
+
+	assert.Len(t, deps[0].Files, 2)
+	var paths []string
+	for _, f := range deps[1].Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"example/v1/service.proto", "README.md"}, paths)
+
+	// The files the dependency was planned with are left untouched
+	assert.Len(t, previous, 4)
+	assert.Equal(t, "google/api/annotations.proto", previous[1].Path)
+}
+
+func TestCollisionError(t *testing.T) {
+	err := &CollisionError{Collisions: []*Collision{
+		{Path: "google/api/annotations.proto", Repos: []string{"github.com/googleapis/googleapis", "github.com/example/fork"}},
+	}}
+	assert.Equal(t, `files provided by several dependencies, choose a winner under collisions.winners or set collisions.dedupe for identical copies:
+  google/api/annotations.proto: provided by github.com/googleapis/googleapis, github.com/example/fork (different content)`, err.Error())
+}
+
+// fakeSource serves fixed files for every dependency, by repo, and the same license for all of them
+type fakeSource struct {
+	files   map[string][]*file.File
+	license *file.File
+	// licenseReads counts the calls to License
+	licenseReads int
+}
+
+func (s *fakeSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*source.Pin, error) {
+	return &source.Pin{Version: "v1"}, nil
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *source.Pin) ([]*file.File, error) {
+	return slices.Clone(s.files[dep.Repo]), nil
+}
+
+func (s *fakeSource) License(ctx context.Context, dep config.Buf3pdDep, pin *source.Pin) (*file.File, error) {
+	s.licenseReads++
+	return s.license, nil
+}
+
+func TestPlanDependenciesSwitchingWinner(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+	outputPath := t.TempDir()
+
+	sources := source.NewRegistry()
+	sources.Register("fake", &fakeSource{files: map[string][]*file.File{
+		"github.com/googleapis/googleapis": {
+			{Path: "google/api/annotations.proto", Content: []byte("upstream")},
+			{Path: "google/api/http.proto", Content: []byte("http")},
+		},
+		"github.com/example/fork": {
+			{Path: "example/v1/service.proto", Content: []byte("service")},
+			{Path: "google/api/annotations.proto", Content: []byte("fork")},
+		},
+	}})
+	manager := NewDependencyManager(file.NewManager(), lock.NewFileManager(), sources)
+
+	cfg := &config.Config{
+		Deps: []config.Buf3pdDep{
+			{Type: "fake", Repo: "github.com/googleapis/googleapis", Ref: "main"},
+			{Type: "fake", Repo: "github.com/example/fork", Ref: "main"},
+		},
+		Collisions: &config.CollisionsConfig{Winners: map[string]string{"google/api/*.proto": "github.com/googleapis/googleapis"}},
+	}
+	lockFile := &lock.File{Version: lock.CurrentVersion, Deps: []*lock.Dep{}}
+
+	sync := func() *Plan {
+		plan, err := manager.PlanDependencies(ctx, cfg, lockFile, outputPath)
+		require.NoError(t, err)
+		require.NoError(t, manager.ApplyPlan(ctx, plan, lockFile, outputPath))
+		return plan
+	}
+
+	sync()
+	assert.Equal(t, []*lock.DroppedFile{
+		{Path: "google/api/annotations.proto", Kept: "github.com/googleapis/googleapis", Resolution: CollisionWinner},
+	}, lockFile.Deps[1].Dropped)
+	assert.NoFileExists(t, filepath.Join(outputPath, "fork", "google/api/annotations.proto"))
+
+	// An unchanged config reuses the vendored files
+	plan := sync()
+	assert.False(t, plan.HasChanges())
+
+	// Switching the winner fetches the dependency that lost the file again
+	cfg.Collisions.Winners["google/api/*.proto"] = "github.com/example/fork"
+	plan = sync()
+	assert.Equal(t, OriginRemote, plan.Results[1].Origin)
+	assert.Equal(t, []string{"google/api/annotations.proto"}, plan.Results[0].Removed)
+	assert.Empty(t, lockFile.Deps[1].Dropped)
+	assert.NoFileExists(t, filepath.Join(outputPath, "googleapis", "google/api/annotations.proto"))
+
+	content, err := os.ReadFile(filepath.Join(outputPath, "fork", "google/api/annotations.proto"))
+	require.NoError(t, err)
+	assert.Equal(t, "fork", string(content))
+
+	// Removing the winning dependency restores the file to the dependency that lost it
+	cfg.Collisions.Winners["google/api/*.proto"] = "github.com/googleapis/googleapis"
+	sync()
+	assert.NoFileExists(t, filepath.Join(outputPath, "fork", "google/api/annotations.proto"))
+
+	cfg.Deps = cfg.Deps[1:]
+	cfg.Collisions = nil
+	sync()
+	assert.FileExists(t, filepath.Join(outputPath, "fork", "google/api/annotations.proto"))
+}
+
+func TestPlanDependenciesEveryFileDropped(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	sources := source.NewRegistry()
+	sources.Register("fake", &fakeSource{files: map[string][]*file.File{
+		"github.com/googleapis/googleapis": {
+			{Path: "google/api/annotations.proto", Content: []byte("annotations")},
+			{Path: "google/api/http.proto", Content: []byte("http")},
+		},
+		// A filtered dependency whose files all also come from googleapis
+		"github.com/example/annotations": {
+			{Path: "google/api/annotations.proto", Content: []byte("annotations")},
+		},
+	}})
+	manager := NewDependencyManager(file.NewManager(), lock.NewFileManager(), sources)
+
+	cfg := &config.Config{
+		Deps: []config.Buf3pdDep{
+			{Type: "fake", Repo: "github.com/googleapis/googleapis", Ref: "main"},
+			{Type: "fake", Repo: "github.com/example/annotations", Ref: "main"},
+		},
+		Collisions: &config.CollisionsConfig{Dedupe: true},
+	}
+	lockFile := &lock.File{Version: lock.CurrentVersion, Deps: []*lock.Dep{}}
+
+	_, err := manager.PlanDependencies(ctx, cfg, lockFile, t.TempDir())
+	assert.ErrorContains(t, err, "github.com/example/annotations: every file is also provided by github.com/googleapis/googleapis and was dropped")
+}
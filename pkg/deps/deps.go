@@ -7,17 +7,21 @@ import (
 	"strings"
 
 	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/bufdigest"
 	"github.com/walteh/buf3pd/pkg/config"
 	"github.com/walteh/buf3pd/pkg/file"
 	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
 	"gitlab.com/tozd/go/errors"
 )
 
 // DepFiles represents a set of dependency files
 type DepFiles struct {
-	DepInfo        config.Buf3pdDep `yaml:"dep"`
-	Files          []*file.File     `yaml:"files"`
-	CommitMetadata string
+	DepInfo config.Buf3pdDep `yaml:"dep"`
+	Files   []*file.File     `yaml:"files"`
+	Pin     *source.Pin      `yaml:"pin"`
+	// License is the license file at the root of the repository, copied next to the vendored files
+	License *file.File `yaml:"license"`
 }
 
 // SortedFiles returns the files sorted by path
@@ -28,23 +32,55 @@ func (d *DepFiles) SortedFiles() []*file.File {
 
 // LockEntry creates a lock entry for this dependency
 func (d *DepFiles) LockEntry(fileHandler file.Handler) (*lock.Dep, error) {
-	digest, err := fileHandler.CalculateDigest(d.Files)
+	fileDigests, err := fileHandler.CalculateFileDigests(d.Files)
+	if err != nil {
+		return nil, errors.Errorf("calculating file digests: %w", err)
+	}
+
+	digest, err := file.ManifestDigest(fileDigests)
 	if err != nil {
 		return nil, errors.Errorf("calculating digest: %w", err)
 	}
 
+	moduleDigest, err := d.ModuleDigest()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := lock.LockDepMetadata{
+		Type: d.DepInfo.Type,
+	}
+	if d.Pin != nil {
+		metadata.Commit = d.Pin.Version
+		metadata.Source = d.Pin.Metadata
+	}
+
 	return &lock.Dep{
-		Metadata: lock.LockDepMetadata{
-			Type:   d.DepInfo.Type,
-			Commit: d.CommitMetadata,
-		},
-		Repo:   d.DepInfo.Repo,
-		Path:   d.DepInfo.Path,
-		Ref:    d.DepInfo.Ref,
-		Digest: digest,
+		Metadata:     metadata,
+		ModuleDigest: moduleDigest,
+		Repo:         d.DepInfo.Repo,
+		Path:         d.DepInfo.Path,
+		Ref:          d.DepInfo.Ref,
+		Digest:       digest,
+		Files:        fileDigests,
 	}, nil
 }
 
+// ModuleDigest calculates the buf b5 digest of the vendored module, which includes the license copy
+// written next to the files
+func (d *DepFiles) ModuleDigest() (string, error) {
+	files := d.Files
+	if d.License != nil {
+		files = append(slices.Clone(d.Files), d.License)
+	}
+
+	digest, err := bufdigest.B5(files)
+	if err != nil {
+		return "", errors.Errorf("calculating module digest: %w", err)
+	}
+	return digest, nil
+}
+
 // WriteToDir writes all files to a directory
 func (d *DepFiles) WriteToDir(fileHandler file.Handler, relPath string) error {
 	return fileHandler.WriteFiles(d.Files, relPath)
@@ -80,16 +116,24 @@ func (d *DepFiles) AddAllNestedProtoFiles(ctx context.Context, fileHandler file.
 		}
 	}
 
+	d.sortFiles()
+
+	return nil
+}
+
+// sortFiles sorts the files by path
+func (d *DepFiles) sortFiles() {
 	slices.SortFunc(d.Files, func(a, b *file.File) int {
 		return strings.Compare(a.Path, b.Path)
 	})
-
-	return nil
 }
 
 // Manager provides an interface for managing dependencies
 type Manager interface {
-	ProcessDependencies(ctx context.Context, config *config.Config, lockFile *lock.File, outputPath string) error
-	CheckLocalDependency(ctx context.Context, cfg *config.Config, dep config.Buf3pdDep) (*DepFiles, bool, error)
+	ProcessDependencies(ctx context.Context, config *config.Config, lockFile *lock.File, outputPath string) ([]*Result, error)
+	PlanDependencies(ctx context.Context, config *config.Config, lockFile *lock.File, outputPath string) (*Plan, error)
+	ApplyPlan(ctx context.Context, plan *Plan, lockFile *lock.File, outputPath string) error
+	VerifyDependencies(ctx context.Context, cfg *config.Config, lockFile *lock.File, outputPath string) ([]*Verification, error)
+	CheckLocalDependency(ctx context.Context, outputPath string, dep config.Buf3pdDep) (*DepFiles, bool, error)
 	FetchRemoteDependency(ctx context.Context, dep config.Buf3pdDep) (*DepFiles, error)
 }
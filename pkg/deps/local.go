@@ -14,12 +14,12 @@ import (
 // NewDepFilesFromLocal creates a DepFiles from a local directory
 func NewDepFilesFromLocal(
 	ctx context.Context,
-	cfg *config.Config,
+	outputPath string,
 	dep config.Buf3pdDep,
 	fileHandler file.Handler,
 ) (*DepFiles, bool, error) {
 
-	pth := filepath.Join(cfg.Path, filepath.Base(dep.Repo))
+	pth := filepath.Join(outputPath, filepath.Base(dep.Repo))
 
 	zerolog.Ctx(ctx).Info().Str("path", pth).Msg("processing local dependency")
 
@@ -31,20 +31,22 @@ func NewDepFilesFromLocal(
 
 	// Find all proto files in the directory
 	protoFiles, err := fileHandler.FindProtoFiles(pth, dep.Filter)
-	if err != nil {
+	if err != nil && !errors.Is(err, file.ErrNoProtoFiles) {
 		return nil, false, errors.Errorf("finding proto files: %w", err)
 	}
 
-	if len(protoFiles) == 0 {
-		zerolog.Ctx(ctx).Warn().Str("path", pth).Msg("no proto files found, skipping local dependency")
-		return nil, false, nil
-	}
-
 	depFiles := &DepFiles{
 		DepInfo: dep,
 		Files:   []*file.File{},
 	}
 
+	// The directory may only hold other files, such as a license copy, so the dependency is vendored
+	// without any files
+	if len(protoFiles) == 0 {
+		zerolog.Ctx(ctx).Warn().Str("path", pth).Msg("no proto files found in local dependency")
+		return depFiles, true, nil
+	}
+
 	for _, filePath := range protoFiles {
 		if err := depFiles.AddFile(fileHandler, pth, filePath); err != nil {
 			return nil, false, errors.Errorf("adding file: %w", err)
@@ -2,29 +2,31 @@ package deps
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 
 	"github.com/rs/zerolog"
 	"github.com/walteh/buf3pd/pkg/config"
 	"github.com/walteh/buf3pd/pkg/file"
-	"github.com/walteh/buf3pd/pkg/git"
+	"github.com/walteh/buf3pd/pkg/license"
 	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
 	"gitlab.com/tozd/go/errors"
 )
 
 // DependencyManager implements the Manager interface
 type DependencyManager struct {
 	fileHandler file.Handler
-	gitHandler  git.Handler
 	lockManager lock.Manager
+	sources     *source.Registry
 }
 
 // NewDependencyManager creates a new DependencyManager
-func NewDependencyManager(fileHandler file.Handler, gitHandler git.Handler, lockManager lock.Manager) *DependencyManager {
+func NewDependencyManager(fileHandler file.Handler, lockManager lock.Manager, sources *source.Registry) *DependencyManager {
 	return &DependencyManager{
 		fileHandler: fileHandler,
-		gitHandler:  gitHandler,
 		lockManager: lockManager,
+		sources:     sources,
 	}
 }
 
@@ -34,106 +36,348 @@ func (m *DependencyManager) ProcessDependencies(
 	config *config.Config,
 	lockFile *lock.File,
 	outputPath string,
-) error {
+) ([]*Result, error) {
+	plan, err := m.PlanDependencies(ctx, config, lockFile, outputPath)
+	if err != nil {
+		return nil, errors.Errorf("planning dependencies: %w", err)
+	}
+
+	if err := m.ApplyPlan(ctx, plan, lockFile, outputPath); err != nil {
+		return nil, errors.Errorf("applying plan: %w", err)
+	}
+
+	return plan.Results, nil
+}
+
+// PlanDependencies computes what processing the dependencies would change without writing anything
+func (m *DependencyManager) PlanDependencies(
+	ctx context.Context,
+	config *config.Config,
+	lockFile *lock.File,
+	outputPath string,
+) (*Plan, error) {
 	log := zerolog.Ctx(ctx)
-	depFilesToUpdate := []*DepFiles{}
+	plan := &Plan{
+		Results:  []*Result{},
+		LockDeps: []*lock.Dep{},
+	}
 
 	for _, dep := range config.Deps {
-		if dep.Type != "git" {
-			log.Warn().Str("type", dep.Type).Msg("unsupported dependency type, skipping")
+		if _, ok := m.sources.Lookup(dep.Type); !ok {
+			log.Warn().Str("type", dep.Type).Strs("supported", m.sources.Types()).Msg("unsupported dependency type, skipping")
+			// Keep the existing lock entry so skipping a dependency never drops it
+			if storedLockDep := m.lockManager.EntryFor(lockFile, dep); storedLockDep != nil {
+				plan.LockDeps = append(plan.LockDeps, storedLockDep)
+			}
 			continue
 		}
 
-		storedLockDep := m.lockManager.EntryFor(lockFile, dep)
-
-		var ok bool
-		var tryLoc *DepFiles
-		var err error
-
-		// Check if dependency is already processed locally
-		tryLoc, ok, err = m.CheckLocalDependency(ctx, config, dep)
+		planned, err := m.planDependency(ctx, dep, lockFile, outputPath, false)
 		if err != nil {
-			return errors.Errorf("checking local dependency: %w", err)
+			return nil, err
 		}
 
-		var depFiles *DepFiles
-		var lockDep *lock.Dep
-		var skipRemote = false
+		plan.Results = append(plan.Results, planned.result)
+		plan.LockDeps = append(plan.LockDeps, planned.lockDep)
+		plan.depFiles = append(plan.depFiles, planned.depFiles)
+		plan.previousFiles = append(plan.previousFiles, planned.previousFiles)
+	}
 
-		if ok {
-			// Local dependency found
-			realLockDep, err := tryLoc.LockEntry(m.fileHandler)
+	// Dependencies vendored without the colliding files another dependency won lack those files
+	// locally, so they are fetched again whenever the collision would be resolved differently
+	for stale := staleDropped(plan, config.Collisions); len(stale) > 0; stale = staleDropped(plan, config.Collisions) {
+		for _, i := range stale {
+			log.Info().Str("repo", plan.Dep(i).Repo).Msg("collision resolution changed, fetching dependency again")
+			planned, err := m.planDependency(ctx, plan.Dep(i), lockFile, outputPath, true)
 			if err != nil {
-				return errors.Errorf("creating lock entry: %w", err)
+				return nil, err
 			}
+			plan.replace(i, planned)
+		}
+	}
 
-			if storedLockDep != nil && storedLockDep.Compare(realLockDep) {
-				log.Info().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("dependency already processed")
-				skipRemote = true
-				realLockDep.Metadata = storedLockDep.Metadata
-			} else {
-				log.Warn().Any("storedLockDep", storedLockDep).Any("realLockDep", realLockDep).Msg("dependency already processed, but with different commit")
-			}
+	if err := m.resolveCollisions(plan, config.Collisions); err != nil {
+		return nil, errors.Errorf("resolving file collisions: %w", err)
+	}
+
+	plan.LockChanges = diffLock(lockFile.Deps, plan.LockDeps)
+	plan.Pruned = prunedDirs(lockFile.Deps, plan.LockDeps)
 
-			log.Info().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("using local dependency")
-			depFiles = tryLoc
-			lockDep = realLockDep
+	return plan, nil
+}
+
+// plannedDep is the plan of a single dependency
+type plannedDep struct {
+	result        *Result
+	lockDep       *lock.Dep
+	depFiles      *DepFiles
+	previousFiles []*file.File
+}
+
+// planDependency plans a single dependency, reusing its vendored files when they match the lock
+// file unless refetch is set
+func (m *DependencyManager) planDependency(
+	ctx context.Context,
+	dep config.Buf3pdDep,
+	lockFile *lock.File,
+	outputPath string,
+	refetch bool,
+) (*plannedDep, error) {
+	log := zerolog.Ctx(ctx)
+	storedLockDep := m.lockManager.EntryFor(lockFile, dep)
+
+	// Check if dependency is already processed locally
+	tryLoc, ok, err := m.CheckLocalDependency(ctx, outputPath, dep)
+	if err != nil {
+		return nil, errors.Errorf("checking local dependency: %w", err)
+	}
+
+	var depFiles *DepFiles
+	var lockDep *lock.Dep
+	var skipRemote = false
+	var origin = OriginLocal
+	var previousFiles []*file.File
+
+	// A local dependency without proto files is fetched again
+	if ok && len(tryLoc.Files) > 0 {
+		// Local dependency found
+		realLockDep, err := tryLoc.LockEntry(m.fileHandler)
+		if err != nil {
+			return nil, errors.Errorf("creating lock entry: %w", err)
 		}
 
-		if !skipRemote {
-			// No local dependency, fetch from remote
-			log.Info().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("processing git dependency from remote")
+		matches, err := m.matchesLockEntry(storedLockDep, realLockDep, tryLoc.Files)
+		if err != nil {
+			return nil, errors.Errorf("comparing lock entry: %w", err)
+		}
 
-			remoteDepFiles, err := m.FetchRemoteDependency(ctx, dep)
-			if err != nil {
-				return errors.Errorf("fetching remote dependency: %w", err)
-			}
+		if matches && !refetch {
+			log.Info().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("dependency already processed")
+			skipRemote = true
+			realLockDep.Metadata = storedLockDep.Metadata
+			realLockDep.Dropped = storedLockDep.Dropped
 
-			remoteLockDep, err := remoteDepFiles.LockEntry(m.fileHandler)
-			if err != nil {
-				return errors.Errorf("creating lock entry: %w", err)
+			// Entries locked before licenses were recorded get the license of their locked commit, which
+			// also restores a license copy deleted from the vendored directory
+			if realLockDep.Metadata.Commit != "" && (realLockDep.Metadata.License == "" || licenseMissing(outputPath, dep, realLockDep)) {
+				if err := m.addLicense(ctx, dep, &source.Pin{Version: realLockDep.Metadata.Commit}, tryLoc, realLockDep); err != nil {
+					return nil, err
+				}
+			} else if licenseFile := realLockDep.Metadata.LicenseFile; licenseFile != "" {
+				// The vendored license copy stands in for the one of the locked commit
+				content, err := m.fileHandler.ReadFile(filepath.Join(outputPath, filepath.Base(dep.Repo), licenseFile))
+				if err != nil {
+					return nil, errors.Errorf("reading license file: %w", err)
+				}
+				tryLoc.License = &file.File{Path: licenseFile, Content: content}
+				if realLockDep.ModuleDigest, err = tryLoc.ModuleDigest(); err != nil {
+					return nil, err
+				}
 			}
+		} else if !matches {
+			log.Warn().Any("storedLockDep", storedLockDep).Any("realLockDep", realLockDep).Msg("dependency already processed, but with different commit")
+		}
+
+		log.Info().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("using local dependency")
+		depFiles = tryLoc
+		lockDep = realLockDep
+		previousFiles = tryLoc.Files
+	}
+
+	if !skipRemote {
+		// No local dependency, fetch from remote
+		log.Info().Str("type", dep.Type).Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("processing dependency from remote")
 
-			depFiles = remoteDepFiles
-			lockDep = remoteLockDep
+		remoteDepFiles, err := m.FetchRemoteDependency(ctx, dep)
+		if err != nil {
+			return nil, errors.Errorf("fetching remote dependency: %w", err)
 		}
 
-		// Update lock file
-		if storedLockDep != nil {
-			*storedLockDep = *lockDep
-		} else {
-			lockFile.Deps = append(lockFile.Deps, lockDep)
+		remoteLockDep, err := remoteDepFiles.LockEntry(m.fileHandler)
+		if err != nil {
+			return nil, errors.Errorf("creating lock entry: %w", err)
 		}
 
-		depFilesToUpdate = append(depFilesToUpdate, depFiles)
+		if err := m.addLicense(ctx, dep, remoteDepFiles.Pin, remoteDepFiles, remoteLockDep); err != nil {
+			return nil, err
+		}
 
-		log.Info().Str("repo", dep.Repo).Str("prefix", lockDep.Prefix).Msg("successfully processed dependency")
+		depFiles = remoteDepFiles
+		lockDep = remoteLockDep
+		origin = OriginRemote
 	}
 
-	// Write updated dependencies to output directory
-	for _, depFiles := range depFilesToUpdate {
-		err := depFiles.WriteToDir(m.fileHandler, filepath.Join(outputPath, filepath.Base(depFiles.DepInfo.Repo)))
-		if err != nil {
+	result := NewResult(lockDep, origin, previousFiles, depFiles.Files)
+	if previous := previousLockDep(lockFile, storedLockDep, dep); previous != nil {
+		result.PreviousCommit = previous.Metadata.Commit
+	}
+
+	log.Info().Str("repo", dep.Repo).Str("prefix", lockDep.Prefix).Msg("successfully processed dependency")
+
+	return &plannedDep{
+		result:        result,
+		lockDep:       lockDep,
+		depFiles:      depFiles,
+		previousFiles: previousFiles,
+	}, nil
+}
+
+// ApplyPlan writes the planned dependency files to the output directory and updates the lock file
+func (m *DependencyManager) ApplyPlan(
+	ctx context.Context,
+	plan *Plan,
+	lockFile *lock.File,
+	outputPath string,
+) error {
+	previousLicenses := make(map[string]string, len(lockFile.Deps))
+	for _, dep := range lockFile.Deps {
+		previousLicenses[dep.Repo] = dep.Metadata.LicenseFile
+	}
+	planned := make(map[string]*lock.Dep, len(plan.LockDeps))
+	for _, dep := range plan.LockDeps {
+		planned[dep.Repo] = dep
+	}
+
+	// Write updated dependencies to output directory, removing files the dependency no longer provides
+	for i, depFiles := range plan.depFiles {
+		depDir := filepath.Join(outputPath, filepath.Base(depFiles.DepInfo.Repo))
+		if err := m.fileHandler.RemoveFiles(plan.Results[i].Removed, depDir); err != nil {
+			return errors.Errorf("removing stale dependency files: %w", err)
+		}
+		if err := depFiles.WriteToDir(m.fileHandler, depDir); err != nil {
 			return errors.Errorf("writing dependency files: %w", err)
 		}
+
+		// Replace the copied license file when the repository's license file changed
+		previous := previousLicenses[depFiles.DepInfo.Repo]
+		if lockDep := planned[depFiles.DepInfo.Repo]; previous != "" && lockDep != nil && lockDep.Metadata.License != "" && lockDep.Metadata.LicenseFile != previous {
+			if err := m.fileHandler.RemoveFiles([]string{previous}, depDir); err != nil {
+				return errors.Errorf("removing stale license file: %w", err)
+			}
+		}
+		// A license copy alone would not make a vendored module
+		if depFiles.License != nil && len(depFiles.Files) > 0 {
+			if err := m.fileHandler.WriteFiles([]*file.File{depFiles.License}, depDir); err != nil {
+				return errors.Errorf("writing license file: %w", err)
+			}
+		}
 	}
 
+	// Remove the directories of dependencies that were dropped from the config
+	for _, dir := range plan.Pruned {
+		zerolog.Ctx(ctx).Info().Str("dir", dir).Msg("pruning dependency")
+		if err := m.fileHandler.RemoveDir(filepath.Join(outputPath, dir)); err != nil {
+			return errors.Errorf("pruning dependency: %w", err)
+		}
+	}
+
+	for _, change := range plan.LockChanges {
+		zerolog.Ctx(ctx).Info().Str("action", change.Action).Str("repo", change.Repo).Str("path", change.Path).Str("ref", change.Ref).Msg("updating lock entry")
+	}
+
+	lockFile.Deps = plan.LockDeps
+
 	return nil
 }
 
-// CheckLocalDependency checks if a dependency exists locally
+// addLicense reads the license file of a dependency at a pin from its source, recording its SPDX
+// identifier in the lock entry so it can be copied next to the vendored files. Dependencies whose
+// source cannot read licenses are left without one.
+func (m *DependencyManager) addLicense(ctx context.Context, dep config.Buf3pdDep, pin *source.Pin, depFiles *DepFiles, lockDep *lock.Dep) error {
+	src, ok := m.sources.Lookup(dep.Type)
+	if !ok || pin == nil {
+		return nil
+	}
+	licenser, ok := source.As[source.LicenseSource](src)
+	if !ok {
+		return nil
+	}
+
+	licenseFile, err := licenser.License(ctx, dep, pin)
+	if err != nil {
+		return errors.Errorf("reading license of %s: %w", dep.Repo, err)
+	}
+
+	depFiles.License = licenseFile
+	lockDep.Metadata.License = license.Of(licenseFile)
+	if licenseFile != nil {
+		lockDep.Metadata.LicenseFile = licenseFile.Path
+	}
+
+	// buf includes a LICENSE file in the module digest
+	if lockDep.ModuleDigest, err = depFiles.ModuleDigest(); err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Info().Str("repo", dep.Repo).Str("license", lockDep.Metadata.License).Msg("detected license")
+
+	return nil
+}
+
+// licenseMissing reports whether the license file recorded for a dependency is missing from its
+// vendored directory
+func licenseMissing(outputPath string, dep config.Buf3pdDep, lockDep *lock.Dep) bool {
+	if lockDep.Metadata.LicenseFile == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(outputPath, filepath.Base(dep.Repo), lockDep.Metadata.LicenseFile))
+	return os.IsNotExist(err)
+}
+
+// previousLockDep returns the lock entry a dependency was previously vendored from: its own entry,
+// or the entry of the same repo and path when only its ref changed
+func previousLockDep(lockFile *lock.File, stored *lock.Dep, dep config.Buf3pdDep) *lock.Dep {
+	if stored != nil {
+		return stored
+	}
+	for _, lockDep := range lockFile.Deps {
+		if lockDep.Repo == dep.Repo && lockDep.Path == dep.Path {
+			return lockDep
+		}
+	}
+	return nil
+}
+
+// matchesLockEntry reports whether the stored lock entry describes the local files. Entries
+// migrated from a v2 lock file are compared using the legacy digest.
+func (m *DependencyManager) matchesLockEntry(stored *lock.Dep, local *lock.Dep, files []*file.File) (bool, error) {
+	if stored == nil {
+		return false, nil
+	}
+
+	if !stored.HasLegacyDigest() {
+		return stored.Compare(local), nil
+	}
+
+	legacyDigest, err := m.fileHandler.CalculateLegacyDigest(files)
+	if err != nil {
+		return false, errors.Errorf("calculating legacy digest: %w", err)
+	}
+
+	legacy := *local
+	legacy.Digest = legacyDigest
+
+	return stored.Compare(&legacy), nil
+}
+
+// CheckLocalDependency checks if a dependency exists locally in the output directory
 func (m *DependencyManager) CheckLocalDependency(
 	ctx context.Context,
-	cfg *config.Config,
+	outputPath string,
 	dep config.Buf3pdDep,
 ) (*DepFiles, bool, error) {
-	return NewDepFilesFromLocal(ctx, cfg, dep, m.fileHandler)
+	return NewDepFilesFromLocal(ctx, outputPath, dep, m.fileHandler)
 }
 
-// FetchRemoteDependency fetches a dependency from a remote repository
+// FetchRemoteDependency fetches a dependency using the source registered for its type
 func (m *DependencyManager) FetchRemoteDependency(
 	ctx context.Context,
 	dep config.Buf3pdDep,
 ) (*DepFiles, error) {
-	return NewDepFilesFromRemote(ctx, dep, m.fileHandler, m.gitHandler)
+	src, ok := m.sources.Lookup(dep.Type)
+	if !ok {
+		return nil, errors.Errorf("no source registered for dependency type %q", dep.Type)
+	}
+
+	return NewDepFilesFromRemote(ctx, dep, src)
 }
@@ -0,0 +1,107 @@
+package deps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+)
+
+func TestPlanDependenciesRestoresLicense(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+	outputPath := t.TempDir()
+
+	src := &fakeSource{
+		files: map[string][]*file.File{
+			"github.com/example/repo": {{Path: "example/v1/example.proto", Content: []byte(`syntax = "proto3";`)}},
+		},
+		license: &file.File{Path: "LICENSE", Content: []byte("SPDX-License-Identifier: MIT\n")},
+	}
+	sources := source.NewRegistry()
+	sources.Register("fake", src)
+	manager := NewDependencyManager(file.NewManager(), lock.NewFileManager(), sources)
+
+	cfg := &config.Config{Deps: []config.Buf3pdDep{{Type: "fake", Repo: "github.com/example/repo", Ref: "main"}}}
+	lockFile := &lock.File{Version: lock.CurrentVersion, Deps: []*lock.Dep{}}
+	licensePath := filepath.Join(outputPath, "repo", "LICENSE")
+
+	sync := func() *Plan {
+		plan, err := manager.PlanDependencies(ctx, cfg, lockFile, outputPath)
+		require.NoError(t, err)
+		require.NoError(t, manager.ApplyPlan(ctx, plan, lockFile, outputPath))
+		return plan
+	}
+
+	sync()
+	assert.Equal(t, "MIT", lockFile.Deps[0].Metadata.License)
+	assert.FileExists(t, licensePath)
+
+	// An up to date dependency uses its vendored license copy without reading it from the source
+	plan := sync()
+	assert.Equal(t, 1, src.licenseReads)
+	licenseFile, recorded := plan.License(0)
+	require.NotNil(t, licenseFile)
+	assert.Equal(t, "LICENSE", licenseFile.Path)
+	assert.Equal(t, "MIT", recorded)
+
+	// A deleted license copy is restored without fetching the dependency again
+	require.NoError(t, os.Remove(licensePath))
+	plan = sync()
+	assert.Equal(t, OriginLocal, plan.Results[0].Origin)
+	assert.Empty(t, plan.LockChanges)
+
+	content, err := os.ReadFile(licensePath)
+	require.NoError(t, err)
+	assert.Equal(t, "SPDX-License-Identifier: MIT\n", string(content))
+}
+
+func TestDirectoryWithOnlyLicense(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+	outputPath := t.TempDir()
+
+	sources := source.NewRegistry()
+	sources.Register("fake", &fakeSource{
+		files: map[string][]*file.File{
+			"github.com/example/repo": {{Path: "example/v1/example.proto", Content: []byte(`syntax = "proto3";`)}},
+		},
+		license: &file.File{Path: "LICENSE", Content: []byte("SPDX-License-Identifier: MIT\n")},
+	})
+	manager := NewDependencyManager(file.NewManager(), lock.NewFileManager(), sources)
+
+	cfg := &config.Config{Deps: []config.Buf3pdDep{{Type: "fake", Repo: "github.com/example/repo", Ref: "main"}}}
+	lockFile := &lock.File{Version: lock.CurrentVersion, Deps: []*lock.Dep{}}
+	protoPath := filepath.Join(outputPath, "repo", "example/v1/example.proto")
+
+	plan, err := manager.PlanDependencies(ctx, cfg, lockFile, outputPath)
+	require.NoError(t, err)
+	require.NoError(t, manager.ApplyPlan(ctx, plan, lockFile, outputPath))
+
+	// Deleting the vendored files leaves the directory holding the license copy alone
+	require.NoError(t, os.RemoveAll(filepath.Join(outputPath, "repo", "example")))
+
+	local, ok, err := manager.CheckLocalDependency(ctx, outputPath, cfg.Deps[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, local.Files)
+
+	verifications, err := manager.VerifyDependencies(ctx, cfg, lockFile, outputPath)
+	require.NoError(t, err)
+	require.Len(t, verifications, 1)
+	assert.False(t, verifications[0].OK)
+	assert.Equal(t, []string{"example/v1/example.proto"}, verifications[0].Missing)
+
+	// Syncing fetches the dependency again
+	plan, err = manager.PlanDependencies(ctx, cfg, lockFile, outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, OriginRemote, plan.Results[0].Origin)
+	require.NoError(t, manager.ApplyPlan(ctx, plan, lockFile, outputPath))
+	assert.FileExists(t, protoPath)
+}
@@ -0,0 +1,143 @@
+package deps
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+)
+
+// Lock change actions
+const (
+	LockAdded   = "added"
+	LockUpdated = "updated"
+	LockRemoved = "removed"
+)
+
+// LockChange describes how a single lock entry changes when a plan is applied
+type LockChange struct {
+	Action string    `json:"action"`
+	Repo   string    `json:"repo"`
+	Path   string    `json:"path"`
+	Ref    string    `json:"ref"`
+	Before *lock.Dep `json:"before,omitempty"`
+	After  *lock.Dep `json:"after,omitempty"`
+}
+
+// Plan describes everything processing the dependencies would change, without changing it
+type Plan struct {
+	Results     []*Result     `json:"deps"`
+	LockChanges []*LockChange `json:"lock"`
+	LockDeps    []*lock.Dep   `json:"-"`
+	// Pruned are the dependency directories of the output path no dependency is vendored into anymore
+	Pruned []string `json:"pruned"`
+	// Collisions are the files several dependencies provide, and which copy of them is vendored
+	Collisions []*Collision `json:"collisions"`
+
+	depFiles      []*DepFiles
+	previousFiles [][]*file.File
+}
+
+// Files returns the previously vendored and the planned files of the i-th result
+func (p *Plan) Files(i int) (previous []*file.File, current []*file.File) {
+	return p.previousFiles[i], p.depFiles[i].Files
+}
+
+// Dep returns the configured dependency of the i-th result
+func (p *Plan) Dep(i int) config.Buf3pdDep {
+	return p.depFiles[i].DepInfo
+}
+
+// License returns the license file of the i-th result, nil if its repository has none, and the
+// SPDX identifier recorded for it, which is empty when the dependency's source cannot read licenses
+func (p *Plan) License(i int) (*file.File, string) {
+	for _, lockDep := range p.LockDeps {
+		if lockDep.Repo == p.Results[i].Repo {
+			return p.depFiles[i].License, lockDep.Metadata.License
+		}
+	}
+	return p.depFiles[i].License, ""
+}
+
+// replace replaces the plan of the i-th result, along with its lock entry
+func (p *Plan) replace(i int, planned *plannedDep) {
+	for j, lockDep := range p.LockDeps {
+		if lockDep.Repo == p.Results[i].Repo {
+			p.LockDeps[j] = planned.lockDep
+		}
+	}
+	p.Results[i] = planned.result
+	p.depFiles[i] = planned.depFiles
+	p.previousFiles[i] = planned.previousFiles
+}
+
+// HasChanges reports whether applying the plan would change any files or lock entries
+func (p *Plan) HasChanges() bool {
+	if len(p.LockChanges) > 0 {
+		return true
+	}
+	for _, result := range p.Results {
+		if result.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// prunedDirs returns the dependency directories of the current lock entries that none of the
+// planned ones are vendored into
+func prunedDirs(before []*lock.Dep, after []*lock.Dep) []string {
+	vendored := make(map[string]bool, len(after))
+	for _, dep := range after {
+		vendored[filepath.Base(dep.Repo)] = true
+	}
+
+	pruned := []string{}
+	for _, dep := range before {
+		dir := filepath.Base(dep.Repo)
+		if dir == "." || dir == ".." || dir == string(filepath.Separator) {
+			continue
+		}
+		if !vendored[dir] && !slices.Contains(pruned, dir) {
+			pruned = append(pruned, dir)
+		}
+	}
+	slices.Sort(pruned)
+
+	return pruned
+}
+
+// diffLock computes the changes between the current lock entries and the planned ones
+func diffLock(before []*lock.Dep, after []*lock.Dep) []*LockChange {
+	changes := []*LockChange{}
+
+	type key struct{ repo, path, ref string }
+	beforeByKey := make(map[key]*lock.Dep, len(before))
+	for _, dep := range before {
+		beforeByKey[key{dep.Repo, dep.Path, dep.Ref}] = dep
+	}
+
+	afterKeys := make(map[key]bool, len(after))
+	for _, dep := range after {
+		k := key{dep.Repo, dep.Path, dep.Ref}
+		afterKeys[k] = true
+
+		prev, ok := beforeByKey[k]
+		switch {
+		case !ok:
+			changes = append(changes, &LockChange{Action: LockAdded, Repo: dep.Repo, Path: dep.Path, Ref: dep.Ref, After: dep})
+		case !prev.Equal(dep):
+			changes = append(changes, &LockChange{Action: LockUpdated, Repo: dep.Repo, Path: dep.Path, Ref: dep.Ref, Before: prev, After: dep})
+		}
+	}
+
+	for _, dep := range before {
+		if !afterKeys[key{dep.Repo, dep.Path, dep.Ref}] {
+			changes = append(changes, &LockChange{Action: LockRemoved, Repo: dep.Repo, Path: dep.Path, Ref: dep.Ref, Before: dep})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,25 @@
+package deps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walteh/buf3pd/pkg/lock"
+)
+
+func TestPrunedDirs(t *testing.T) {
+	before := []*lock.Dep{
+		{Repo: "github.com/googleapis/googleapis", Path: "google/api"},
+		{Repo: "github.com/googleapis/googleapis", Path: "google/rpc"},
+		{Repo: "github.com/bufbuild/protovalidate", Path: "proto"},
+		{Repo: "github.com/example/dropped", Path: "proto"},
+	}
+	after := []*lock.Dep{
+		// Changing the path within a repo keeps its directory
+		{Repo: "github.com/googleapis/googleapis", Path: "google"},
+		{Repo: "github.com/bufbuild/protovalidate", Path: "proto"},
+	}
+
+	assert.Equal(t, []string{"dropped"}, prunedDirs(before, after))
+	assert.Empty(t, prunedDirs(after, after))
+}
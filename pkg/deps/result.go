@@ -0,0 +1,95 @@
+package deps
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+)
+
+// Origins a processed dependency's files can come from
+const (
+	OriginLocal  = "local"
+	OriginRemote = "remote"
+)
+
+// Result describes the outcome of processing a single dependency. PreviousCommit is the commit
+// recorded in the lock file before processing, and ModuleDigest the buf b5 digest of the files.
+type Result struct {
+	Type           string   `json:"type"`
+	Repo           string   `json:"repo"`
+	Path           string   `json:"path"`
+	Ref            string   `json:"ref"`
+	Commit         string   `json:"commit"`
+	PreviousCommit string   `json:"previous_commit,omitempty"`
+	Digest         string   `json:"digest"`
+	ModuleDigest   string   `json:"module_digest"`
+	Origin         string   `json:"origin"`
+	Added          []string `json:"added"`
+	Removed        []string `json:"removed"`
+	Changed        []string `json:"changed"`
+}
+
+// NewResult creates a Result for a lock entry, diffing the previous files against the current ones
+func NewResult(lockDep *lock.Dep, origin string, previous []*file.File, current []*file.File) *Result {
+	added, removed, changed := DiffFiles(previous, current)
+
+	return &Result{
+		Type:         lockDep.Metadata.Type,
+		Repo:         lockDep.Repo,
+		Path:         lockDep.Path,
+		Ref:          lockDep.Ref,
+		Commit:       lockDep.Metadata.Commit,
+		Digest:       lockDep.Digest,
+		ModuleDigest: lockDep.ModuleDigest,
+		Origin:       origin,
+		Added:        added,
+		Removed:      removed,
+		Changed:      changed,
+	}
+}
+
+// HasChanges reports whether any files were added, removed or changed
+func (r *Result) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+// CommitMoved reports whether the dependency moved from a previously locked commit to a different one
+func (r *Result) CommitMoved() bool {
+	return r.PreviousCommit != "" && r.PreviousCommit != r.Commit
+}
+
+// DiffFiles returns the sorted paths added, removed and changed between two sets of files
+func DiffFiles(previous []*file.File, current []*file.File) (added []string, removed []string, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+
+	previousByPath := make(map[string]*file.File, len(previous))
+	for _, f := range previous {
+		previousByPath[f.Path] = f
+	}
+
+	currentByPath := make(map[string]*file.File, len(current))
+	for _, f := range current {
+		currentByPath[f.Path] = f
+
+		prev, ok := previousByPath[f.Path]
+		if !ok {
+			added = append(added, f.Path)
+		} else if !bytes.Equal(prev.Content, f.Content) {
+			changed = append(changed, f.Path)
+		}
+	}
+
+	for _, f := range previous {
+		if _, ok := currentByPath[f.Path]; !ok {
+			removed = append(removed, f.Path)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+
+	return added, removed, changed
+}
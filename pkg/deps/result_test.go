@@ -0,0 +1,32 @@
+package deps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+func TestDiffFiles(t *testing.T) {
+	previous := []*file.File{
+		{Path: "a.proto", Content: []byte("a")},
+		{Path: "b.proto", Content: []byte("b")},
+		{Path: "c.proto", Content: []byte("c")},
+	}
+	current := []*file.File{
+		{Path: "d.proto", Content: []byte("d")},
+		{Path: "b.proto", Content: []byte("b2")},
+		{Path: "a.proto", Content: []byte("a")},
+	}
+
+	added, removed, changed := DiffFiles(previous, current)
+	assert.Equal(t, []string{"d.proto"}, added)
+	assert.Equal(t, []string{"c.proto"}, removed)
+	assert.Equal(t, []string{"b.proto"}, changed)
+
+	// Fetching into an empty output directory adds everything
+	added, removed, changed = DiffFiles(nil, current)
+	assert.Equal(t, []string{"a.proto", "b.proto", "d.proto"}, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, changed)
+}
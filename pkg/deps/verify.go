@@ -0,0 +1,127 @@
+package deps
+
+import (
+	"context"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Verification describes whether a dependency's vendored files still match its lock entry
+type Verification struct {
+	Repo           string   `json:"repo"`
+	Path           string   `json:"path"`
+	Ref            string   `json:"ref"`
+	Locked         bool     `json:"locked"`
+	ExpectedDigest string   `json:"expected_digest,omitempty"`
+	ActualDigest   string   `json:"actual_digest,omitempty"`
+	Modified       []string `json:"modified"`
+	Missing        []string `json:"missing"`
+	Unexpected     []string `json:"unexpected"`
+	OK             bool     `json:"ok"`
+}
+
+// VerifyDependencies checks the vendored files of every configured dependency against the lock file
+func (m *DependencyManager) VerifyDependencies(
+	ctx context.Context,
+	cfg *config.Config,
+	lockFile *lock.File,
+	outputPath string,
+) ([]*Verification, error) {
+	log := zerolog.Ctx(ctx)
+	verifications := []*Verification{}
+
+	for _, dep := range cfg.Deps {
+		verification := &Verification{
+			Repo:       dep.Repo,
+			Path:       dep.Path,
+			Ref:        dep.Ref,
+			Modified:   []string{},
+			Missing:    []string{},
+			Unexpected: []string{},
+		}
+		verifications = append(verifications, verification)
+
+		stored := m.lockManager.EntryFor(lockFile, dep)
+		if stored == nil {
+			log.Warn().Str("repo", dep.Repo).Str("path", dep.Path).Str("ref", dep.Ref).Msg("dependency is not locked")
+			continue
+		}
+		verification.Locked = true
+		verification.ExpectedDigest = stored.Digest
+
+		localFiles := []*file.File{}
+		local, ok, err := m.CheckLocalDependency(ctx, outputPath, dep)
+		if err != nil {
+			return nil, errors.Errorf("checking local dependency: %w", err)
+		}
+		if ok {
+			localFiles = local.Files
+		}
+		if len(localFiles) > 0 {
+			localLockDep, err := local.LockEntry(m.fileHandler)
+			if err != nil {
+				return nil, errors.Errorf("creating lock entry: %w", err)
+			}
+			verification.ActualDigest = localLockDep.Digest
+
+			matches, err := m.matchesLockEntry(stored, localLockDep, localFiles)
+			if err != nil {
+				return nil, errors.Errorf("comparing lock entry: %w", err)
+			}
+			verification.OK = matches
+		}
+
+		if len(stored.Files) == 0 {
+			// Entries without a file manifest can only be verified as a whole
+			continue
+		}
+
+		actual, err := m.fileHandler.CalculateFileDigests(localFiles)
+		if err != nil {
+			return nil, errors.Errorf("calculating file digests: %w", err)
+		}
+
+		verification.Modified, verification.Missing, verification.Unexpected = diffFileDigests(stored.Files, actual)
+	}
+
+	return verifications, nil
+}
+
+// diffFileDigests returns the sorted paths whose digest differs, that are only expected, and that are only actual
+func diffFileDigests(expected []*file.Digest, actual []*file.Digest) (modified []string, missing []string, unexpected []string) {
+	modified, missing, unexpected = []string{}, []string{}, []string{}
+
+	actualByPath := make(map[string]string, len(actual))
+	for _, digest := range actual {
+		actualByPath[digest.Path] = digest.Digest
+	}
+
+	expectedByPath := make(map[string]bool, len(expected))
+	for _, digest := range expected {
+		expectedByPath[digest.Path] = true
+
+		actualDigest, ok := actualByPath[digest.Path]
+		if !ok {
+			missing = append(missing, digest.Path)
+		} else if actualDigest != digest.Digest {
+			modified = append(modified, digest.Path)
+		}
+	}
+
+	for _, digest := range actual {
+		if !expectedByPath[digest.Path] {
+			unexpected = append(unexpected, digest.Path)
+		}
+	}
+
+	slices.Sort(modified)
+	slices.Sort(missing)
+	slices.Sort(unexpected)
+
+	return modified, missing, unexpected
+}
@@ -2,6 +2,7 @@ package file
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"os"
 	"path/filepath"
@@ -18,15 +19,28 @@ type File struct {
 	Content []byte `json:"content"`
 }
 
+// Digest represents the digest of a single file's content
+type Digest struct {
+	Path   string `yaml:"path" json:"path"`
+	Digest string `yaml:"digest" json:"digest"`
+}
+
 // Handler provides an interface for file operations
 type Handler interface {
 	FindProtoFiles(directory string, filters []string) ([]string, error)
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, content []byte) error
 	WriteFiles(files []*File, basePath string) error
+	RemoveFiles(paths []string, basePath string) error
+	RemoveDir(path string) error
 	CalculateDigest(files []*File) (string, error)
+	CalculateFileDigests(files []*File) ([]*Digest, error)
+	CalculateLegacyDigest(files []*File) (string, error)
 }
 
+// ErrNoProtoFiles is returned by FindProtoFiles when a directory holds no matching proto files
+var ErrNoProtoFiles = errors.Base("no proto files found")
+
 // Manager implements the Handler interface
 type Manager struct{}
 
@@ -55,7 +69,7 @@ func (m *Manager) FindProtoFiles(directory string, filters []string) ([]string,
 	}
 
 	if len(files) == 0 {
-		return nil, errors.New("no proto files found in: " + directory)
+		return nil, errors.Errorf("%w in: %s", ErrNoProtoFiles, directory)
 	}
 
 	return files, nil
@@ -98,10 +112,87 @@ func (m *Manager) WriteFiles(files []*File, basePath string) error {
 	return nil
 }
 
-const zeroHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+// RemoveFiles removes multiple files relative to a base path, ignoring files that do not exist
+func (m *Manager) RemoveFiles(paths []string, basePath string) error {
+	for _, path := range paths {
+		if err := os.Remove(filepath.Join(basePath, path)); err != nil && !os.IsNotExist(err) {
+			return errors.Errorf("removing file: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveDir removes a directory and everything in it, ignoring directories that do not exist
+func (m *Manager) RemoveDir(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return errors.Errorf("removing directory: %w", err)
+	}
+	return nil
+}
+
+// DigestPrefix prefixes digests produced by CalculateDigest and CalculateFileDigests
+const DigestPrefix = "sha256:"
 
-// CalculateDigest calculates a SHA-256 digest for a slice of files
+// CalculateDigest calculates a SHA-256 manifest digest for a slice of files. Each file contributes
+// its length-prefixed path followed by the SHA-256 of its content, so path and content boundaries
+// are unambiguous.
 func (m *Manager) CalculateDigest(files []*File) (string, error) {
+	if len(files) == 0 {
+		return "", errors.New("no files to digest")
+	}
+
+	digests, err := m.CalculateFileDigests(files)
+	if err != nil {
+		return "", errors.Errorf("calculating file digests: %w", err)
+	}
+
+	return ManifestDigest(digests)
+}
+
+// ManifestDigest calculates the manifest digest from per-file digests sorted by path
+func ManifestDigest(digests []*Digest) (string, error) {
+	hash := sha256.New()
+
+	var length [8]byte
+	for _, digest := range digests {
+		sum, err := hex.DecodeString(strings.TrimPrefix(digest.Digest, DigestPrefix))
+		if err != nil {
+			return "", errors.Errorf("decoding digest for %s: %w", digest.Path, err)
+		}
+
+		binary.BigEndian.PutUint64(length[:], uint64(len(digest.Path)))
+		hash.Write(length[:])
+		hash.Write([]byte(digest.Path))
+		hash.Write(sum)
+	}
+
+	return DigestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CalculateFileDigests calculates a SHA-256 digest for each file, sorted by path
+func (m *Manager) CalculateFileDigests(files []*File) ([]*Digest, error) {
+	// ensure the files are sorted
+	slices.SortFunc(files, func(a, b *File) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	digests := make([]*Digest, 0, len(files))
+	for _, file := range files {
+		sum := sha256.Sum256(file.Content)
+		digests = append(digests, &Digest{
+			Path:   file.Path,
+			Digest: DigestPrefix + hex.EncodeToString(sum[:]),
+		})
+	}
+
+	return digests, nil
+}
+
+const zeroHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// CalculateLegacyDigest calculates the v2 lock file digest, a SHA-256 over the concatenated
+// paths and contents of the files. It is only used to verify lock files written before v3.
+func (m *Manager) CalculateLegacyDigest(files []*File) (string, error) {
 	hash := sha256.New()
 
 	// ensure the files are sorted
@@ -3,6 +3,7 @@ package file
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -125,3 +126,43 @@ func TestFindProtoFiles(t *testing.T) {
 	assert.True(t, foundDir2)
 	assert.True(t, foundSubdir)
 }
+
+func TestCalculateDigestBoundaries(t *testing.T) {
+	manager := NewManager()
+
+	// The legacy digest cannot tell where a path ends and its content begins
+	legacy1, err := manager.CalculateLegacyDigest([]*File{{Path: "a", Content: []byte("bc")}})
+	assert.NoError(t, err)
+	legacy2, err := manager.CalculateLegacyDigest([]*File{{Path: "ab", Content: []byte("c")}})
+	assert.NoError(t, err)
+	assert.Equal(t, legacy1, legacy2)
+
+	digest1, err := manager.CalculateDigest([]*File{{Path: "a", Content: []byte("bc")}})
+	assert.NoError(t, err)
+	digest2, err := manager.CalculateDigest([]*File{{Path: "ab", Content: []byte("c")}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest1, digest2)
+	assert.True(t, strings.HasPrefix(digest1, DigestPrefix))
+}
+
+func TestCalculateFileDigests(t *testing.T) {
+	files := []*File{
+		{Path: "b.proto", Content: []byte("b")},
+		{Path: "a.proto", Content: []byte("")},
+	}
+
+	manager := NewManager()
+	digests, err := manager.CalculateFileDigests(files)
+	assert.NoError(t, err)
+	assert.Len(t, digests, 2)
+	assert.Equal(t, "a.proto", digests[0].Path)
+	assert.Equal(t, DigestPrefix+"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", digests[0].Digest)
+	assert.Equal(t, "b.proto", digests[1].Path)
+
+	// The manifest digest can be recomputed from the per-file digests alone
+	digest, err := manager.CalculateDigest(files)
+	assert.NoError(t, err)
+	manifest, err := ManifestDigest(digests)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, manifest)
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 
 	"gitlab.com/tozd/go/errors"
@@ -11,9 +12,20 @@ import (
 // Handler provides an interface for git operations
 type Handler interface {
 	Clone(repo string, path string) error
-	FetchTags(repoPath string) error
+	ResolveRef(repo string, ref string) (string, error)
+	FetchCommit(repoPath string, commit string) error
 	Checkout(repoPath string, ref string) error
 	GetCommitHash(repoPath string) (string, error)
+	CloneHistory(repo string, path string) error
+	Log(repoPath string, from string, to string, paths ...string) ([]*Commit, error)
+}
+
+// Commit is a commit listed by Log
+type Commit struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
 }
 
 // Manager implements the Handler interface
@@ -26,16 +38,67 @@ func NewManager() *Manager {
 
 // Clone clones a git repository to a local path
 func (m *Manager) Clone(repo string, path string) error {
-	cmd := exec.Command("git", "clone", "--depth", "1", "https://"+repo, path)
+	cmd := exec.Command("git", "clone", "--depth", "1", RemoteURL(repo), path)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return errors.Errorf("git clone: %w: %s", err, string(output))
 	}
 	return nil
 }
 
-// FetchTags fetches tags from the origin
-func (m *Manager) FetchTags(repoPath string) error {
-	cmd := exec.Command("git", "fetch", "origin", "--tags")
+var commitHashRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ResolveRef resolves a reference (branch, tag, or commit) to a commit hash without cloning
+func (m *Manager) ResolveRef(repo string, ref string) (string, error) {
+	// ls-remote only lists the peeled commit of an annotated tag for a pattern matching it
+	cmd := exec.Command("git", "ls-remote", RemoteURL(repo), ref, ref+"^{}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.Errorf("git ls-remote: %w", err)
+	}
+
+	if commit, ok := matchRef(string(output), ref); ok {
+		return commit, nil
+	}
+
+	// the reference may already be a commit hash, which ls-remote cannot list
+	if commitHashRegex.MatchString(ref) {
+		return ref, nil
+	}
+
+	return "", errors.Errorf("reference %q not found in %s", ref, repo)
+}
+
+// refPrefixes are the prefixes git tries, in order, to expand a short reference to a full ref name
+var refPrefixes = []string{"", "refs/", "refs/tags/", "refs/heads/"}
+
+// matchRef finds the commit of a reference in ls-remote output. ls-remote lists every ref whose
+// name ends with the reference, so only the ref the reference expands to is used, and the peeled
+// commit of an annotated tag is preferred over the tag object.
+func matchRef(output string, ref string) (string, bool) {
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	for _, prefix := range refPrefixes {
+		name := prefix + ref
+		if commit, ok := refs[name+"^{}"]; ok {
+			return commit, true
+		}
+		if commit, ok := refs[name]; ok {
+			return commit, true
+		}
+	}
+
+	return "", false
+}
+
+// FetchCommit fetches a single commit from the origin
+func (m *Manager) FetchCommit(repoPath string, commit string) error {
+	cmd := exec.Command("git", "fetch", "--depth", "1", "origin", commit)
 	cmd.Dir = repoPath
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return errors.Errorf("git fetch: %w: %s", err, string(output))
@@ -64,6 +127,42 @@ func (m *Manager) GetCommitHash(repoPath string) (string, error) {
 	return strings.TrimSpace(string(commitHash)), nil
 }
 
+// CloneHistory clones the full history of a git repository without file contents into a bare
+// repository at a local path, which is enough to list commits cheaply
+func (m *Manager) CloneHistory(repo string, path string) error {
+	cmd := exec.Command("git", "clone", "--bare", "--filter=blob:none", RemoteURL(repo), path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Errorf("git clone: %w: %s", err, string(output))
+	}
+	return nil
+}
+
+// Log lists the commits reachable from to but not from, newest first, limited to those touching paths if any are given
+func (m *Manager) Log(repoPath string, from string, to string, paths ...string) ([]*Commit, error) {
+	args := []string{"log", "--format=%H%x1f%an%x1f%aI%x1f%s", from + ".." + to}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Errorf("git log: %w", err)
+	}
+
+	commits := []*Commit{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, &Commit{Hash: fields[0], Author: fields[1], Date: fields[2], Subject: fields[3]})
+	}
+
+	return commits, nil
+}
+
 // CreateTempDir creates a temporary directory for git operations
 func CreateTempDir() (string, error) {
 	tempDir, err := os.MkdirTemp("", "buf3pd-git-")
@@ -0,0 +1,56 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Jane", "GIT_AUTHOR_EMAIL=jane@example.com", "GIT_COMMITTER_NAME=Jane", "GIT_COMMITTER_EMAIL=jane@example.com")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return string(output)
+	}
+	commit := func(path string, message string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(repo, path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, path), []byte(message), 0644))
+		run("add", "-A")
+		run("commit", "-q", "-m", message)
+	}
+
+	run("init", "-q")
+	commit("proto/a.proto", "Initial protos")
+	manager := NewManager()
+	from, err := manager.GetCommitHash(repo)
+	require.NoError(t, err)
+
+	commit("proto/a.proto", "Change a.proto")
+	commit("README", "Docs only")
+	to, err := manager.GetCommitHash(repo)
+	require.NoError(t, err)
+
+	commits, err := manager.Log(repo, from, to)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "Docs only", commits[0].Subject)
+	assert.Equal(t, to, commits[0].Hash)
+	assert.Equal(t, "Jane", commits[0].Author)
+
+	commits, err = manager.Log(repo, from, to, "proto")
+	require.NoError(t, err)
+	require.Len(t, commits, 1)
+	assert.Equal(t, "Change a.proto", commits[0].Subject)
+
+	commits, err = manager.Log(repo, to, to)
+	require.NoError(t, err)
+	assert.Empty(t, commits)
+}
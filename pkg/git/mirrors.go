@@ -0,0 +1,104 @@
+package git
+
+import (
+	"os"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// MirrorsEnv is the environment variable holding mirrors that take precedence over the config,
+// as comma-separated prefix=url pairs
+const MirrorsEnv = "BUF3PD_MIRRORS"
+
+// Mirrors maps upstream repository prefixes, such as github.com/googleapis, to the URL of a mirror
+type Mirrors map[string]string
+
+// ParseMirrors parses comma-separated prefix=url pairs
+func ParseMirrors(s string) (Mirrors, error) {
+	mirrors := Mirrors{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		prefix, url, ok := strings.Cut(pair, "=")
+		if !ok || prefix == "" || url == "" {
+			return nil, errors.Errorf("invalid mirror %q: expected prefix=url", pair)
+		}
+		mirrors[strings.TrimSuffix(prefix, "/")] = strings.TrimSuffix(url, "/")
+	}
+	return mirrors, nil
+}
+
+// MirrorsFromEnv returns the configured mirrors overridden by those in the MirrorsEnv variable
+func MirrorsFromEnv(configured map[string]string) (Mirrors, error) {
+	mirrors := Mirrors{}
+	for prefix, url := range configured {
+		mirrors[strings.TrimSuffix(prefix, "/")] = strings.TrimSuffix(url, "/")
+	}
+
+	env, err := ParseMirrors(os.Getenv(MirrorsEnv))
+	if err != nil {
+		return nil, errors.Errorf("parsing %s: %w", MirrorsEnv, err)
+	}
+	for prefix, url := range env {
+		mirrors[prefix] = url
+	}
+
+	return mirrors, nil
+}
+
+// Rewrite returns the location to fetch repo from, replacing the longest prefix that matches
+// whole path elements of repo with its mirror. Repos without a mirror are returned unchanged.
+func (m Mirrors) Rewrite(repo string) string {
+	match := ""
+	for prefix := range m {
+		if (repo == prefix || strings.HasPrefix(repo, prefix+"/")) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return repo
+	}
+	return m[match] + strings.TrimPrefix(repo, match)
+}
+
+// RemoteURL returns the URL git fetches repo from. Repos are written without a scheme and fetched
+// over https, while mirrors may be full URLs of any scheme git supports.
+func RemoteURL(repo string) string {
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") {
+		return repo
+	}
+	return "https://" + repo
+}
+
+// MirrorHandler is a Handler that fetches repositories from their mirrors. Repositories keep their
+// upstream names everywhere else, so lock files stay portable between environments.
+type MirrorHandler struct {
+	Handler
+	mirrors Mirrors
+}
+
+// NewMirrorHandler creates a MirrorHandler rewriting the repositories passed to handler
+func NewMirrorHandler(handler Handler, mirrors Mirrors) *MirrorHandler {
+	return &MirrorHandler{
+		Handler: handler,
+		mirrors: mirrors,
+	}
+}
+
+// Clone clones a git repository from its mirror to a local path
+func (m *MirrorHandler) Clone(repo string, path string) error {
+	return m.Handler.Clone(m.mirrors.Rewrite(repo), path)
+}
+
+// ResolveRef resolves a reference against the repository's mirror
+func (m *MirrorHandler) ResolveRef(repo string, ref string) (string, error) {
+	return m.Handler.ResolveRef(m.mirrors.Rewrite(repo), ref)
+}
+
+// CloneHistory clones the history of a git repository from its mirror to a local path
+func (m *MirrorHandler) CloneHistory(repo string, path string) error {
+	return m.Handler.CloneHistory(m.mirrors.Rewrite(repo), path)
+}
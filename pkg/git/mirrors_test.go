@@ -0,0 +1,80 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorsRewrite(t *testing.T) {
+	mirrors := Mirrors{
+		"github.com/googleapis":            "https://gitea.internal/mirror/googleapis",
+		"github.com/googleapis/googleapis": "ssh://git@gitea.internal/googleapis.git",
+		"github.com/bufbuild":              "gitea.internal/bufbuild",
+	}
+
+	assert.Equal(t, "ssh://git@gitea.internal/googleapis.git", mirrors.Rewrite("github.com/googleapis/googleapis"))
+	assert.Equal(t, "https://gitea.internal/mirror/googleapis/api-common-protos", mirrors.Rewrite("github.com/googleapis/api-common-protos"))
+	assert.Equal(t, "gitea.internal/bufbuild/protovalidate", mirrors.Rewrite("github.com/bufbuild/protovalidate"))
+	// Prefixes only match whole path elements
+	assert.Equal(t, "github.com/bufbuildx/repo", mirrors.Rewrite("github.com/bufbuildx/repo"))
+	assert.Equal(t, "github.com/other/repo", mirrors.Rewrite("github.com/other/repo"))
+}
+
+func TestMirrorsFromEnv(t *testing.T) {
+	t.Setenv(MirrorsEnv, "github.com/googleapis=https://env.internal/googleapis/, github.com/grpc=file:///srv/grpc")
+
+	mirrors, err := MirrorsFromEnv(map[string]string{
+		"github.com/googleapis": "https://config.internal/googleapis",
+		"github.com/bufbuild/":  "https://config.internal/bufbuild",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Mirrors{
+		"github.com/googleapis": "https://env.internal/googleapis",
+		"github.com/grpc":       "file:///srv/grpc",
+		"github.com/bufbuild":   "https://config.internal/bufbuild",
+	}, mirrors)
+
+	t.Setenv(MirrorsEnv, "github.com/googleapis")
+	_, err = MirrorsFromEnv(nil)
+	assert.Error(t, err)
+}
+
+func TestRemoteURL(t *testing.T) {
+	assert.Equal(t, "https://github.com/googleapis/googleapis", RemoteURL("github.com/googleapis/googleapis"))
+	assert.Equal(t, "ssh://git@gitea.internal/googleapis.git", RemoteURL("ssh://git@gitea.internal/googleapis.git"))
+	assert.Equal(t, "git@github.com:googleapis/googleapis.git", RemoteURL("git@github.com:googleapis/googleapis.git"))
+}
+
+// recordingHandler records the repositories passed to it
+type recordingHandler struct {
+	Handler
+	repos []string
+}
+
+func (h *recordingHandler) Clone(repo string, path string) error {
+	h.repos = append(h.repos, repo)
+	return nil
+}
+
+func (h *recordingHandler) ResolveRef(repo string, ref string) (string, error) {
+	h.repos = append(h.repos, repo)
+	return "0123456789abcdef0123456789abcdef01234567", nil
+}
+
+func TestMirrorHandler(t *testing.T) {
+	recorder := &recordingHandler{}
+	handler := NewMirrorHandler(recorder, Mirrors{"github.com/googleapis": "https://gitea.internal/googleapis"})
+
+	_, err := handler.ResolveRef("github.com/googleapis/googleapis", "heads/master")
+	require.NoError(t, err)
+	require.NoError(t, handler.Clone("github.com/googleapis/googleapis", t.TempDir()))
+	require.NoError(t, handler.Clone("github.com/bufbuild/protovalidate", t.TempDir()))
+
+	assert.Equal(t, []string{
+		"https://gitea.internal/googleapis/googleapis",
+		"https://gitea.internal/googleapis/googleapis",
+		"github.com/bufbuild/protovalidate",
+	}, recorder.repos)
+}
@@ -0,0 +1,137 @@
+package license
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"buf.build/go/spdx"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+// Special SPDX values for dependencies without an identified license
+const (
+	// None is the license of a repository without a license file
+	None = "NONE"
+	// NoAssertion is the license of a repository whose license file is not recognized
+	NoAssertion = "NOASSERTION"
+)
+
+// fileNames are the base names, without extension, of the license files at a repository root
+var fileNames = []string{"license", "licence", "copying"}
+
+// IsLicenseFile reports whether a path at a repository root names a license file, such as LICENSE,
+// LICENSE.md or COPYING
+func IsLicenseFile(name string) bool {
+	if strings.Contains(name, "/") {
+		return false
+	}
+	base := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+	return slices.Contains(fileNames, base)
+}
+
+// classifier identifies a license by keywords that all appear in its normalized text
+type classifier struct {
+	id       string
+	keywords []string
+	// gnu is set for GNU licenses, whose identifier lacks the -only or -or-later suffix the text
+	// cannot decide, see gnuVariant
+	gnu bool
+}
+
+// classifiers are checked in order, so licenses whose text contains another's keywords come first
+var classifiers = []classifier{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}, false},
+	{"MPL-2.0", []string{"mozilla public license", "version 2.0"}, false},
+	{"EPL-2.0", []string{"eclipse public license", "2.0"}, false},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}, true},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}, true},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}, true},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}, true},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}, true},
+	{"BSL-1.0", []string{"boost software license"}, false},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}, false},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}, false},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted"}, false},
+	{"MIT", []string{"permission is hereby granted, free of charge"}, false},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}, false},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "names of its contributors may be used"}, false},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}, false},
+	{"Zlib", []string{"this software is provided 'as-is'", "altered source versions must be plainly marked"}, false},
+}
+
+// identifierPattern matches an SPDX-License-Identifier tag
+var identifierPattern = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// whitespace matches runs of whitespace, collapsed when normalizing license text
+var whitespace = regexp.MustCompile(`\s+`)
+
+// orLaterNotice is the wording of a GNU license notice allowing any later version of the license
+const orLaterNotice = "or (at your option) any later version"
+
+// Identify returns the SPDX identifier of a license text, or an empty string if it is not
+// recognized. An SPDX-License-Identifier tag in the text takes precedence over its wording, which is
+// only matched heuristically, by keywords of the common licenses.
+func Identify(content []byte) string {
+	if match := identifierPattern.FindSubmatch(content); match != nil {
+		if known, ok := spdx.LicenseForID(string(match[1])); ok {
+			return known.ID
+		}
+	}
+
+	text := strings.ToLower(whitespace.ReplaceAllString(string(content), " "))
+	for _, c := range classifiers {
+		if !containsAll(text, c.keywords) {
+			continue
+		}
+		if c.gnu {
+			return gnuVariant(text, c.id)
+		}
+		return c.id
+	}
+
+	return ""
+}
+
+// gnuVariant returns the -or-later identifier of a GNU license when its text is a notice allowing
+// any later version, and an empty string otherwise. The text of a GNU license does not say whether a
+// program may use later versions, since that is stated in the program's notices, and the full text
+// ends with an example notice allowing them, so only a notice without the terms and conditions is
+// identified.
+func gnuVariant(text string, id string) string {
+	if strings.Contains(text, "terms and conditions") || !strings.Contains(text, orLaterNotice) {
+		return ""
+	}
+	return id + "-or-later"
+}
+
+// Of returns the SPDX identifier recorded for a repository's license file, which is nil when the
+// repository has none
+func Of(licenseFile *file.File) string {
+	if licenseFile == nil {
+		return None
+	}
+	if id := Identify(licenseFile.Content); id != "" {
+		return id
+	}
+	return NoAssertion
+}
+
+// Canonical returns the SPDX identifier with its canonical casing, reporting whether it is known
+func Canonical(id string) (string, bool) {
+	known, ok := spdx.LicenseForID(id)
+	if !ok {
+		return "", false
+	}
+	return known.ID, true
+}
+
+func containsAll(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if !strings.Contains(text, keyword) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package license
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		id      string
+	}{
+		{
+			name: "apache",
+			content: `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/
+`,
+			id: "Apache-2.0",
+		},
+		{
+			name: "mit",
+			content: `MIT License
+
+Copyright (c) 2020 Example
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+`,
+			id: "MIT",
+		},
+		{
+			name: "bsd 3 clause",
+			content: `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+`,
+			id: "BSD-3-Clause",
+		},
+		{
+			name: "bsd 2 clause",
+			content: `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+`,
+			id: "BSD-2-Clause",
+		},
+		{
+			name: "lgpl before gpl",
+			content: `This library is free software; you can redistribute it and/or modify it under the terms of
+the GNU Lesser General Public License as published by the Free Software Foundation; either
+version 3 of the License, or (at your option) any later version.
+`,
+			id: "LGPL-3.0-or-later",
+		},
+		{
+			name: "gpl notice allowing later versions",
+			content: `This program is free software; you can redistribute it and/or modify it under the terms of
+the GNU General Public License as published by the Free Software Foundation; either version 2
+of the License, or (at your option) any later version.
+`,
+			id: "GPL-2.0-or-later",
+		},
+		{
+			// The text alone does not say whether later versions may be used
+			name:    "gpl title",
+			content: "GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n",
+			id:      "",
+		},
+		{
+			// The full text ends with an example notice allowing later versions
+			name: "full gpl text",
+			content: `                    GNU GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+                       TERMS AND CONDITIONS
+
+  0. Definitions.
+
+                     END OF TERMS AND CONDITIONS
+
+            How to Apply These Terms to Your New Programs
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+`,
+			id: "",
+		},
+		{
+			name: "gpl notice for a single version",
+			content: `This program is free software; you can redistribute it and/or modify it under the terms of
+version 2 of the GNU General Public License as published by the Free Software Foundation.
+`,
+			id: "",
+		},
+		{
+			name:    "gpl spdx identifier",
+			content: "SPDX-License-Identifier: GPL-2.0-only\n",
+			id:      "GPL-2.0-only",
+		},
+		{
+			name:    "older apache version",
+			content: "Apache License\nVersion 1.1\n",
+			id:      "",
+		},
+		{
+			name:    "permission without the mit wording",
+			content: "Permission is hereby granted to use this file internally.\n",
+			id:      "",
+		},
+		{
+			name:    "spdx identifier",
+			content: "// SPDX-License-Identifier: mpl-2.0\n",
+			id:      "MPL-2.0",
+		},
+		{
+			name:    "unknown spdx identifier falls back to the text",
+			content: "SPDX-License-Identifier: Custom-1.0\nPermission is hereby granted, free of charge\n",
+			id:      "MIT",
+		},
+		{
+			name:    "unrecognized",
+			content: "All rights reserved.\n",
+			id:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.id, Identify([]byte(tt.content)))
+		})
+	}
+}
+
+func TestIsLicenseFile(t *testing.T) {
+	for _, name := range []string{"LICENSE", "LICENSE.md", "License.txt", "LICENCE", "COPYING"} {
+		assert.True(t, IsLicenseFile(name), name)
+	}
+	for _, name := range []string{"README.md", "LICENSES", "third_party/LICENSE", "license-header.txt"} {
+		assert.False(t, IsLicenseFile(name), name)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	id, ok := Canonical("apache-2.0")
+	assert.True(t, ok)
+	assert.Equal(t, "Apache-2.0", id)
+
+	_, ok = Canonical("Custom-1.0")
+	assert.False(t, ok)
+}
+
+func TestOf(t *testing.T) {
+	assert.Equal(t, None, Of(nil))
+	assert.Equal(t, NoAssertion, Of(&file.File{Path: "LICENSE", Content: []byte("All rights reserved.")}))
+	assert.Equal(t, "MIT", Of(&file.File{Path: "LICENSE", Content: []byte("Permission is hereby granted, free of charge")}))
+}
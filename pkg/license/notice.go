@@ -0,0 +1,74 @@
+package license
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// NoticeFileName is the default name of the aggregated notices file
+const NoticeFileName = "THIRD_PARTY_NOTICES"
+
+// Notice is the license notice of a vendored dependency. Text is empty when the license file is
+// not available.
+type Notice struct {
+	Repo    string
+	Path    string
+	Ref     string
+	Commit  string
+	License string
+	Text    string
+}
+
+// separator frames the header of every notice
+var separator = strings.Repeat("=", 80)
+
+// WriteNotices writes the notices of all dependencies, sorted by repo, followed by their license
+// texts. The output only depends on the notices, so it can be committed.
+func WriteNotices(w io.Writer, notices []*Notice) error {
+	sorted := slices.Clone(notices)
+	slices.SortFunc(sorted, func(a, b *Notice) int {
+		return strings.Compare(a.Repo+"\x00"+a.Path, b.Repo+"\x00"+b.Path)
+	})
+
+	var b strings.Builder
+	b.WriteString("THIRD-PARTY SOFTWARE NOTICES\n\n")
+	b.WriteString("This file lists the third-party proto dependencies vendored by buf3pd and their licenses.\n")
+
+	for _, notice := range sorted {
+		fmt.Fprintf(&b, "\n%s\n%s\n", separator, notice.Repo)
+		if notice.Path != "" && notice.Path != "." {
+			fmt.Fprintf(&b, "Path: %s\n", notice.Path)
+		}
+		fmt.Fprintf(&b, "Ref: %s\n", notice.Ref)
+		if notice.Commit != "" {
+			fmt.Fprintf(&b, "Commit: %s\n", notice.Commit)
+		}
+		fmt.Fprintf(&b, "License: %s\n%s\n\n", licenseName(notice.License), separator)
+
+		switch {
+		case notice.Text != "":
+			b.WriteString(strings.TrimRight(notice.Text, "\n") + "\n")
+		case notice.License == None:
+			b.WriteString("The repository has no license file.\n")
+		default:
+			b.WriteString("The license text is not available.\n")
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.Errorf("writing notices: %w", err)
+	}
+	return nil
+}
+
+// licenseName describes a recorded license, which is empty when it was never detected
+func licenseName(id string) string {
+	if id == "" {
+		return "unknown"
+	}
+	return id
+}
@@ -0,0 +1,57 @@
+package license
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteNotices(t *testing.T) {
+	notices := []*Notice{
+		{Repo: "github.com/grpc/grpc", Path: "src/proto", Ref: "tags/v1.0.0", Commit: "abc", License: "Apache-2.0", Text: "Apache License\nVersion 2.0\n\n"},
+		{Repo: "github.com/example/none", Ref: "heads/main", License: None},
+		{Repo: "github.com/example/plugin", Ref: "v1", License: ""},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteNotices(&buf, notices))
+
+	separator := strings.Repeat("=", 80)
+	assert.Equal(t, `THIRD-PARTY SOFTWARE NOTICES
+
+This file lists the third-party proto dependencies vendored by buf3pd and their licenses.
+
+`+separator+`
+github.com/example/none
+Ref: heads/main
+License: NONE
+`+separator+`
+
+The repository has no license file.
+
+`+separator+`
+github.com/example/plugin
+Ref: v1
+License: unknown
+`+separator+`
+
+The license text is not available.
+
+`+separator+`
+github.com/grpc/grpc
+Path: src/proto
+Ref: tags/v1.0.0
+Commit: abc
+License: Apache-2.0
+`+separator+`
+
+Apache License
+Version 2.0
+`, buf.String())
+
+	// The notices are sorted without reordering the caller's slice
+	assert.Equal(t, "github.com/grpc/grpc", notices[0].Repo)
+}
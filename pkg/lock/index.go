@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// IndexFileName is the name of the index kept at the root of a shared vendor directory
+const IndexFileName = "buf3pd.index.yaml"
+
+// IndexVersion is the version of the index written by buf3pd
+const IndexVersion = "v1"
+
+// IndexEntry records which consumers vendor a dependency directory of a shared vendor directory
+type IndexEntry struct {
+	Repo   string `yaml:"repo" json:"repo"`
+	Digest string `yaml:"digest" json:"digest"`
+	// Consumers are the working directories of the projects using the dependency, relative to the index
+	Consumers []string `yaml:"consumers" json:"consumers"`
+}
+
+// Index maps the dependency directories of a shared vendor directory to their consumers
+type Index struct {
+	Version string                 `yaml:"version"`
+	Deps    map[string]*IndexEntry `yaml:"deps"`
+}
+
+// ReadIndex reads the index at path, returning an empty index if it does not exist
+func ReadIndex(path string) (*Index, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Index{
+				Version: IndexVersion,
+				Deps:    map[string]*IndexEntry{},
+			}, nil
+		}
+		return nil, errors.Errorf("reading index: %w", err)
+	}
+
+	var index Index
+	if err := yaml.Unmarshal(content, &index); err != nil {
+		return nil, errors.Errorf("unmarshalling index: %w", err)
+	}
+	if index.Version != IndexVersion {
+		return nil, errors.Errorf("unsupported index version %q", index.Version)
+	}
+	if index.Deps == nil {
+		index.Deps = map[string]*IndexEntry{}
+	}
+
+	return &index, nil
+}
+
+// WriteIndex writes the index to path
+func WriteIndex(index *Index, path string) error {
+	content, err := yaml.Marshal(index)
+	if err != nil {
+		return errors.Errorf("marshaling index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Errorf("creating index directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte("# Generated by buf3pd. DO NOT EDIT.\n"+string(content)), 0644); err != nil {
+		return errors.Errorf("writing index: %w", err)
+	}
+
+	return nil
+}
+
+// Update records that consumer now vendors exactly deps. It returns the dependency directories no
+// consumer references anymore, which may be pruned. A directory already vendored by another
+// consumer from a different repo or with different content is a conflict, since both would
+// overwrite each other's files, unless every other consumer moves to the same content in the same
+// run. moving maps the consumers synced in the same run to the deps they are planned to vendor.
+func (i *Index) Update(consumer string, deps []*Dep, moving map[string][]*Dep) ([]string, error) {
+	vendored := make(map[string]bool, len(deps))
+	var conflicts []string
+	for _, dep := range deps {
+		dir := filepath.Base(dep.Repo)
+		vendored[dir] = true
+
+		entry, ok := i.Deps[dir]
+		if !ok {
+			i.Deps[dir] = &IndexEntry{Repo: dep.Repo, Digest: dep.Digest, Consumers: []string{consumer}}
+			continue
+		}
+
+		others := slices.DeleteFunc(slices.Clone(entry.Consumers), func(c string) bool { return c == consumer })
+		if len(others) > 0 && (entry.Repo != dep.Repo || entry.Digest != dep.Digest) && !movingTogether(others, dir, dep, moving) {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s at %s is also vendored by %s as %s at %s",
+				dir, dep.Repo, dep.Digest, strings.Join(others, ", "), entry.Repo, entry.Digest))
+			continue
+		}
+
+		entry.Repo = dep.Repo
+		entry.Digest = dep.Digest
+		if !slices.Contains(entry.Consumers, consumer) {
+			entry.Consumers = append(entry.Consumers, consumer)
+			sort.Strings(entry.Consumers)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, errors.Errorf("conflicting shared dependencies:\n%s", strings.Join(conflicts, "\n"))
+	}
+
+	var unreferenced []string
+	for dir, entry := range i.Deps {
+		if vendored[dir] {
+			continue
+		}
+		entry.Consumers = slices.DeleteFunc(entry.Consumers, func(c string) bool { return c == consumer })
+		if len(entry.Consumers) == 0 {
+			delete(i.Deps, dir)
+			unreferenced = append(unreferenced, dir)
+		}
+	}
+	sort.Strings(unreferenced)
+
+	return unreferenced, nil
+}
+
+// movingTogether reports whether every consumer is planned to vendor dep into dir in the same run
+func movingTogether(consumers []string, dir string, dep *Dep, moving map[string][]*Dep) bool {
+	for _, consumer := range consumers {
+		planned, ok := moving[consumer]
+		if !ok {
+			return false
+		}
+		same := slices.ContainsFunc(planned, func(other *Dep) bool {
+			return filepath.Base(other.Repo) == dir && other.Repo == dep.Repo && other.Digest == dep.Digest
+		})
+		if !same {
+			return false
+		}
+	}
+	return true
+}
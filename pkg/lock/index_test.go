@@ -0,0 +1,80 @@
+package lock
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), IndexFileName)
+	index, err := ReadIndex(path)
+	require.NoError(t, err)
+
+	googleapis := &Dep{Repo: "github.com/googleapis/googleapis", Digest: "sha256:a"}
+	protovalidate := &Dep{Repo: "github.com/bufbuild/protovalidate", Digest: "sha256:b"}
+
+	pruned, err := index.Update("../svc/a", []*Dep{googleapis, protovalidate}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+
+	pruned, err = index.Update("../svc/b", []*Dep{googleapis}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+	assert.Equal(t, []string{"../svc/a", "../svc/b"}, index.Deps["googleapis"].Consumers)
+
+	// Vendoring a shared dependency at a different version would overwrite the other consumer's files
+	_, err = index.Update("../svc/b", []*Dep{{Repo: "github.com/googleapis/googleapis", Digest: "sha256:c"}}, nil)
+	assert.ErrorContains(t, err, "googleapis: github.com/googleapis/googleapis at sha256:c is also vendored by ../svc/a")
+
+	require.NoError(t, WriteIndex(index, path))
+	index, err = ReadIndex(path)
+	require.NoError(t, err)
+
+	// Dropping a dependency another consumer still uses keeps it
+	pruned, err = index.Update("../svc/a", []*Dep{protovalidate}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+	assert.Equal(t, []string{"../svc/b"}, index.Deps["googleapis"].Consumers)
+
+	// The last consumer dropping it prunes it
+	pruned, err = index.Update("../svc/b", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"googleapis"}, pruned)
+	assert.NotContains(t, index.Deps, "googleapis")
+}
+
+func TestIndexUpdateMovingTogether(t *testing.T) {
+	index, err := ReadIndex(filepath.Join(t.TempDir(), IndexFileName))
+	require.NoError(t, err)
+
+	googleapis := &Dep{Repo: "github.com/googleapis/googleapis", Digest: "sha256:a"}
+	_, err = index.Update("../svc/a", []*Dep{googleapis}, nil)
+	require.NoError(t, err)
+	_, err = index.Update("../svc/b", []*Dep{googleapis}, nil)
+	require.NoError(t, err)
+
+	bumped := &Dep{Repo: "github.com/googleapis/googleapis", Digest: "sha256:c"}
+
+	// Only one consumer moving would overwrite the other's files
+	_, err = index.Update("../svc/a", []*Dep{bumped}, map[string][]*Dep{
+		"../svc/a": {bumped},
+		"../svc/b": {googleapis},
+	})
+	assert.ErrorContains(t, err, "googleapis: github.com/googleapis/googleapis at sha256:c is also vendored by ../svc/b")
+
+	// Both consumers bumping the shared dependency in the same run is not a conflict
+	moving := map[string][]*Dep{
+		"../svc/a": {bumped},
+		"../svc/b": {bumped},
+	}
+	_, err = index.Update("../svc/a", []*Dep{bumped}, moving)
+	require.NoError(t, err)
+	_, err = index.Update("../svc/b", []*Dep{bumped}, moving)
+	require.NoError(t, err)
+
+	assert.Equal(t, "sha256:c", index.Deps["googleapis"].Digest)
+	assert.Equal(t, []string{"../svc/a", "../svc/b"}, index.Deps["googleapis"].Consumers)
+}
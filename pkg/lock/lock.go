@@ -1,28 +1,63 @@
 package lock
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
 	"gitlab.com/tozd/go/errors"
 	"gopkg.in/yaml.v3"
 )
 
+// Lock file versions
+const (
+	VersionV2 = "v2"
+	VersionV3 = "v3"
+
+	// CurrentVersion is the version written by buf3pd
+	CurrentVersion = VersionV3
+)
+
 // LockDepMetadata represents metadata for a dependency entry in the lock file
 type LockDepMetadata struct {
-	Commit string `yaml:"commit"`
-	Type   string `yaml:"type"`
+	Commit string `yaml:"commit" json:"commit"`
+	Type   string `yaml:"type" json:"type"`
+	// License is the SPDX identifier of the repository's license: NONE if it has no license file,
+	// NOASSERTION if its license is not recognized, and empty if the source cannot read licenses
+	License string `yaml:"license,omitempty" json:"license,omitempty"`
+	// LicenseFile is the license file copied next to the vendored files
+	LicenseFile string `yaml:"license_file,omitempty" json:"license_file,omitempty"`
+	// Source holds any additional fields recorded by the dependency's source
+	Source map[string]string `yaml:",inline" json:"source,omitempty"`
 }
 
 // Dep represents a dependency entry in the lock file
 type Dep struct {
-	Repo     string          `yaml:"repo"`
-	Path     string          `yaml:"path"`
-	Ref      string          `yaml:"ref"`
-	Digest   string          `yaml:"digest"`
-	Prefix   string          `yaml:"prefix"`
-	Metadata LockDepMetadata `yaml:"metadata"`
+	Repo     string          `yaml:"repo" json:"repo"`
+	Path     string          `yaml:"path" json:"path"`
+	Ref      string          `yaml:"ref" json:"ref"`
+	Digest   string          `yaml:"digest" json:"digest"`
+	Prefix   string          `yaml:"prefix" json:"prefix"`
+	Metadata LockDepMetadata `yaml:"metadata" json:"metadata"`
+	// ModuleDigest is the buf b5 module digest of the vendored files, comparable with buf.lock digests
+	ModuleDigest string `yaml:"module_digest,omitempty" json:"module_digest,omitempty"`
+	// Files optionally lists the digest of every vendored file so drift can be traced to a file
+	Files []*file.Digest `yaml:"files,omitempty" json:"files,omitempty"`
+	// Dropped lists the colliding files left out in favor of another dependency's copy
+	Dropped []*DroppedFile `yaml:"dropped,omitempty" json:"dropped,omitempty"`
+}
+
+// DroppedFile is a file several dependencies provide that a dependency was vendored without
+type DroppedFile struct {
+	Path string `yaml:"path" json:"path"`
+	// Kept is the dependency whose copy of the file is vendored
+	Kept string `yaml:"kept" json:"kept"`
+	// Resolution is how the collision was resolved, winner or dedupe
+	Resolution string `yaml:"resolution" json:"resolution"`
 }
 
 // File represents the structure of the buf3pd.lock file
@@ -52,7 +87,7 @@ func (m *FileManager) ReadLockFile(path string) (*File, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &File{
-				Version: "v2",
+				Version: CurrentVersion,
 				Deps:    []*Dep{},
 			}, nil
 		}
@@ -64,9 +99,31 @@ func (m *FileManager) ReadLockFile(path string) (*File, error) {
 		return nil, errors.Errorf("unmarshalling lock file: %w", err)
 	}
 
+	if err := migrate(&lockFile); err != nil {
+		return nil, errors.Errorf("migrating lock file: %w", err)
+	}
+
 	return &lockFile, nil
 }
 
+// migrate upgrades a lock file read from disk to the current version. v2 entries keep their
+// legacy digest until the dependency is next processed, which verifies and replaces it.
+func migrate(lockFile *File) error {
+	switch lockFile.Version {
+	case VersionV3:
+	case VersionV2, "":
+		lockFile.Version = VersionV3
+	default:
+		return errors.Errorf("unsupported lock file version %q", lockFile.Version)
+	}
+
+	if lockFile.Deps == nil {
+		lockFile.Deps = []*Dep{}
+	}
+
+	return nil
+}
+
 // WriteLockFile writes the lock file to the given path
 func (m *FileManager) WriteLockFile(file *File, path string) error {
 	lockFileContent, err := yaml.Marshal(file)
@@ -104,3 +161,25 @@ func (l *Dep) Compare(other *Dep) bool {
 		l.Digest == other.Digest &&
 		l.Prefix == other.Prefix
 }
+
+// HasLegacyDigest reports whether the entry's digest was written by a v2 lock file
+func (l *Dep) HasLegacyDigest() bool {
+	return l.Digest != "" && !strings.HasPrefix(l.Digest, file.DigestPrefix)
+}
+
+// Equal reports whether two lock entries are identical, including their metadata
+func (l *Dep) Equal(other *Dep) bool {
+	return l.Compare(other) &&
+		l.Metadata.Commit == other.Metadata.Commit &&
+		l.Metadata.Type == other.Metadata.Type &&
+		l.Metadata.License == other.Metadata.License &&
+		l.Metadata.LicenseFile == other.Metadata.LicenseFile &&
+		l.ModuleDigest == other.ModuleDigest &&
+		maps.Equal(l.Metadata.Source, other.Metadata.Source) &&
+		slices.EqualFunc(l.Files, other.Files, func(a, b *file.Digest) bool {
+			return *a == *b
+		}) &&
+		slices.EqualFunc(l.Dropped, other.Dropped, func(a, b *DroppedFile) bool {
+			return *a == *b
+		})
+}
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLockFileMigratesV2(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testLock := `# Generated by buf3pd. DO NOT EDIT.
+version: v2
+deps:
+    - repo: github.com/example/repo
+      path: proto
+      ref: main
+      digest: 58b59af8ca3bc462683d24f92187783146187e5876a940d9bfbb777b5db558c2
+      prefix: ""
+      metadata:
+        commit: c9f2cb4e4aa2676f9eaea044d36001a2676ef124
+        type: git
+`
+	lockPath := filepath.Join(tempDir, "buf3pd.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte(testLock), 0644))
+
+	manager := NewFileManager()
+	lockFile, err := manager.ReadLockFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, VersionV3, lockFile.Version)
+	require.Len(t, lockFile.Deps, 1)
+	assert.True(t, lockFile.Deps[0].HasLegacyDigest())
+	assert.Equal(t, "c9f2cb4e4aa2676f9eaea044d36001a2676ef124", lockFile.Deps[0].Metadata.Commit)
+	assert.Empty(t, lockFile.Deps[0].Files)
+
+	// Round trip keeps the legacy digest until the dependency is reprocessed
+	require.NoError(t, manager.WriteLockFile(lockFile, lockPath))
+	lockFile, err = manager.ReadLockFile(lockPath)
+	require.NoError(t, err)
+	assert.True(t, lockFile.Deps[0].HasLegacyDigest())
+}
+
+func TestReadLockFileUnsupportedVersion(t *testing.T) {
+	tempDir := t.TempDir()
+
+	lockPath := filepath.Join(tempDir, "buf3pd.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte("version: v99\ndeps: []\n"), 0644))
+
+	_, err := NewFileManager().ReadLockFile(lockPath)
+	assert.Error(t, err)
+}
+
+func TestLockFileLicenseMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	lockPath := filepath.Join(tempDir, "buf3pd.lock")
+
+	lockFile := &File{
+		Version: VersionV3,
+		Deps: []*Dep{{
+			Repo: "github.com/example/repo",
+			Ref:  "main",
+			Metadata: LockDepMetadata{
+				Commit:      "c9f2cb4e4aa2676f9eaea044d36001a2676ef124",
+				Type:        "git",
+				License:     "Apache-2.0",
+				LicenseFile: "LICENSE",
+				Source:      map[string]string{"mirror": "gitea.internal"},
+			},
+		}},
+	}
+
+	manager := NewFileManager()
+	require.NoError(t, manager.WriteLockFile(lockFile, lockPath))
+
+	read, err := manager.ReadLockFile(lockPath)
+	require.NoError(t, err)
+	require.Len(t, read.Deps, 1)
+	assert.True(t, read.Deps[0].Equal(lockFile.Deps[0]))
+	assert.Equal(t, map[string]string{"mirror": "gitea.internal"}, read.Deps[0].Metadata.Source)
+
+	changed := *read.Deps[0]
+	changed.Metadata.License = "MIT"
+	assert.False(t, changed.Equal(lockFile.Deps[0]))
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"os"
+
+	"github.com/walteh/buf3pd/pkg/bsr"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultRef is the ref used for modules whose source does not name one
+const defaultRef = "heads/main"
+
+// Migration is the buf3pd configuration proposed for a set of BSR deps
+type Migration struct {
+	Deps []config.Buf3pdDep `json:"deps"`
+	// Unmapped lists the BSR modules no source repository is known for
+	Unmapped []string `json:"unmapped"`
+	// Unpinned lists the BSR modules pinned by a buf.yaml label or buf.lock commit that were
+	// migrated to a branch or tag, since only git commit labels can be carried over
+	Unpinned []string `json:"unpinned"`
+}
+
+// ReadOverrides reads a YAML list of module sources that take precedence over the known sources
+func ReadOverrides(path string) ([]bsr.Source, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("reading overrides: %w", err)
+	}
+
+	var overrides []bsr.Source
+	if err := yaml.Unmarshal(content, &overrides); err != nil {
+		return nil, errors.Errorf("unmarshalling overrides: %w", err)
+	}
+
+	for _, override := range overrides {
+		if override.Module == "" || override.Repo == "" {
+			return nil, errors.Errorf("override for %q needs both module and repo", override.Module)
+		}
+	}
+
+	return overrides, nil
+}
+
+// Migrate proposes a git dependency for every BSR module in the buf.yaml deps and the buf.lock,
+// which also lists transitive deps that vendored files import. Overrides take precedence over the
+// known sources; modules with neither are reported as unmapped.
+func Migrate(bufDeps []string, bufLock *bsr.Lock, overrides []bsr.Source) (*Migration, error) {
+	var refs []bsr.ModuleRef
+	seen := make(map[string]bool)
+	for _, bufDep := range bufDeps {
+		ref, err := bsr.ParseModuleRef(bufDep)
+		if err != nil {
+			return nil, errors.Errorf("parsing buf.yaml dep: %w", err)
+		}
+		if !seen[ref.FullName()] {
+			seen[ref.FullName()] = true
+			refs = append(refs, ref)
+		}
+	}
+	for _, lockDep := range bufLock.Deps {
+		if !seen[lockDep.Module] {
+			seen[lockDep.Module] = true
+			ref, err := bsr.ParseModuleRef(lockDep.Module)
+			if err != nil {
+				return nil, errors.Errorf("parsing buf.lock dep: %w", err)
+			}
+			refs = append(refs, ref)
+		}
+	}
+
+	locked := make(map[string]bool, len(bufLock.Deps))
+	for _, lockDep := range bufLock.Deps {
+		locked[lockDep.Module] = lockDep.Commit != ""
+	}
+
+	migration := &Migration{Deps: []config.Buf3pdDep{}, Unmapped: []string{}, Unpinned: []string{}}
+	for _, ref := range refs {
+		src, ok := lookup(ref.FullName(), overrides)
+		if !ok {
+			migration.Unmapped = append(migration.Unmapped, ref.FullName())
+			continue
+		}
+
+		gitRef := src.Ref
+		switch {
+		case bsr.IsGitCommit(ref.Ref):
+			gitRef = ref.Ref
+		case gitRef == "":
+			gitRef = defaultRef
+		}
+		if !bsr.IsGitCommit(gitRef) && (ref.Ref != "" || locked[ref.FullName()]) {
+			migration.Unpinned = append(migration.Unpinned, ref.FullName())
+		}
+
+		path := src.Path
+		if path == "" {
+			path = "."
+		}
+
+		migration.Deps = append(migration.Deps, config.Buf3pdDep{
+			Type:   source.GitType,
+			Repo:   src.Repo,
+			Path:   path,
+			Ref:    gitRef,
+			Filter: src.Filter,
+			BSR:    ref.FullName(),
+		})
+	}
+
+	return migration, nil
+}
+
+// lookup returns the source of a module, preferring the overrides
+func lookup(module string, overrides []bsr.Source) (bsr.Source, bool) {
+	for _, override := range overrides {
+		if override.Module == module {
+			return override, true
+		}
+	}
+	return bsr.LookupSource(module)
+}
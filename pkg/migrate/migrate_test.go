@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/bsr"
+	"github.com/walteh/buf3pd/pkg/config"
+)
+
+func TestMigrate(t *testing.T) {
+	bufDeps := []string{
+		"buf.build/googleapis/googleapis:" + "0123456789abcdef0123456789abcdef01234567",
+		"buf.build/acme/protos",
+		"buf.build/acme/labeled:v1.2.0",
+		"buf.build/acme/unknown",
+	}
+	bufLock := &bsr.Lock{
+		Deps: []bsr.LockDep{
+			{Module: "buf.build/googleapis/googleapis", Commit: "cc916c31859748a68fd229a3c8d7a2e8"},
+			{Module: "buf.build/bufbuild/protovalidate", Commit: "a6c49f84cc0f4e038680d390392e2ab0"},
+		},
+	}
+	overrides := []bsr.Source{
+		{Module: "buf.build/acme/protos", Repo: "github.com/acme/protos", Path: "proto"},
+		{Module: "buf.build/acme/labeled", Repo: "github.com/acme/labeled", Ref: "tags/v1.2.0"},
+	}
+
+	migration, err := Migrate(bufDeps, bufLock, overrides)
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.Buf3pdDep{
+		{Type: "git", Repo: "github.com/googleapis/googleapis", Path: ".", Ref: "0123456789abcdef0123456789abcdef01234567", BSR: "buf.build/googleapis/googleapis"},
+		{Type: "git", Repo: "github.com/acme/protos", Path: "proto", Ref: "heads/main", BSR: "buf.build/acme/protos"},
+		{Type: "git", Repo: "github.com/acme/labeled", Path: ".", Ref: "tags/v1.2.0", BSR: "buf.build/acme/labeled"},
+		{Type: "git", Repo: "github.com/bufbuild/protovalidate", Path: "proto/protovalidate", Ref: "heads/main", BSR: "buf.build/bufbuild/protovalidate"},
+	}, migration.Deps)
+	assert.Equal(t, []string{"buf.build/acme/unknown"}, migration.Unmapped)
+
+	// Labels that are not git commits, and buf.lock commits, cannot be carried over as pins
+	assert.Equal(t, []string{"buf.build/acme/labeled", "buf.build/bufbuild/protovalidate"}, migration.Unpinned)
+}
@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bufbuild/protocompile/ast"
+	"github.com/bufbuild/protocompile/parser"
+	"github.com/bufbuild/protocompile/reporter"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/license"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Policy rules
+const (
+	RuleLicenseRequired = "license_required"
+	RuleLicenseAllowed  = "license_allowed"
+	RuleMaxFileSize     = "max_file_size"
+	RulePackageRequired = "package_required"
+	RuleGoPackage       = "go_package"
+)
+
+// Violation is a policy rule a dependency breaks. Path is empty for rules about the dependency as
+// a whole, such as its license.
+type Violation struct {
+	Repo    string `json:"repo"`
+	Path    string `json:"path,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) String() string {
+	if v.Path == "" {
+		return fmt.Sprintf("%s: %s (%s)", v.Repo, v.Message, v.Rule)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", v.Repo, v.Path, v.Message, v.Rule)
+}
+
+// NeedsLicense reports whether checking the policy requires the dependency's license file
+func NeedsLicense(policy *config.PolicyConfig) bool {
+	return policy != nil && (policy.RequireLicense || len(policy.Licenses) > 0)
+}
+
+// Check checks the vendored files and license file of a dependency against its policy. The license
+// file is nil when the repository has none.
+func Check(dep config.Buf3pdDep, files []*file.File, licenseFile *file.File) ([]*Violation, error) {
+	policy := dep.Policy
+	violations := []*Violation{}
+	if policy == nil {
+		return violations, nil
+	}
+
+	violate := func(path string, rule string, format string, args ...any) {
+		violations = append(violations, &Violation{Repo: dep.Repo, Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if NeedsLicense(policy) {
+		switch {
+		case licenseFile == nil:
+			violate("", RuleLicenseRequired, "repository has no LICENSE or COPYING file")
+		case len(policy.Licenses) > 0:
+			id := license.Identify(licenseFile.Content)
+			if id == "" {
+				violate("", RuleLicenseAllowed, "license in %s is not recognized, allowed are %s", licenseFile.Path, strings.Join(policy.Licenses, ", "))
+			} else if !allowed(id, policy.Licenses) {
+				violate("", RuleLicenseAllowed, "license %s is not allowed, allowed are %s", id, strings.Join(policy.Licenses, ", "))
+			}
+		}
+	}
+
+	var goPackage *regexp.Regexp
+	if policy.GoPackage != "" {
+		var err error
+		goPackage, err = regexp.Compile(policy.GoPackage)
+		if err != nil {
+			return nil, errors.Errorf("compiling go_package pattern: %w", err)
+		}
+	}
+
+	for _, f := range files {
+		if policy.MaxFileSize > 0 && int64(len(f.Content)) > policy.MaxFileSize {
+			violate(f.Path, RuleMaxFileSize, "file is %d bytes, larger than %d", len(f.Content), policy.MaxFileSize)
+		}
+
+		if !strings.HasSuffix(f.Path, ".proto") || (!policy.RequirePackage && goPackage == nil) {
+			continue
+		}
+
+		pkg, goPkg, ok := declarations(f)
+		if !ok {
+			// Files that do not parse are reported by the compile check
+			continue
+		}
+		if policy.RequirePackage && pkg == "" {
+			violate(f.Path, RulePackageRequired, "file declares no package")
+		}
+		if goPackage != nil && goPkg != nil && !goPackage.MatchString(*goPkg) {
+			violate(f.Path, RuleGoPackage, "go_package %q does not match %q", *goPkg, policy.GoPackage)
+		}
+	}
+
+	return violations, nil
+}
+
+// allowed reports whether a license is in the allowlist, comparing SPDX identifiers case-insensitively
+func allowed(id string, licenses []string) bool {
+	for _, allowed := range licenses {
+		if strings.EqualFold(id, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// declarations parses a proto file and returns its package and go_package option, which is nil when
+// the file sets none. It reports false if the file does not parse.
+func declarations(f *file.File) (string, *string, bool) {
+	handler := reporter.NewHandler(reporter.NewReporter(func(reporter.ErrorWithPos) error { return nil }, nil))
+	node, err := parser.Parse(f.Path, strings.NewReader(string(f.Content)), handler)
+	if err != nil || node == nil {
+		return "", nil, false
+	}
+
+	var pkg string
+	var goPkg *string
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *ast.PackageNode:
+			pkg = string(decl.Name.AsIdentifier())
+		case *ast.OptionNode:
+			if len(decl.Name.Parts) != 1 || decl.Name.Parts[0].Name.AsIdentifier() != "go_package" {
+				continue
+			}
+			if value, ok := decl.Val.Value().(string); ok {
+				goPkg = &value
+			}
+		}
+	}
+
+	return pkg, goPkg, true
+}
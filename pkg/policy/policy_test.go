@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+func TestCheck(t *testing.T) {
+	apache := &file.File{Path: "LICENSE", Content: []byte("Apache License\nVersion 2.0, January 2004\n")}
+
+	files := []*file.File{
+		{Path: "google/api/http.proto", Content: []byte(`syntax = "proto3";
+package google.api;
+option go_package = "google.golang.org/genproto/googleapis/api/annotations;annotations";
+`)},
+		{Path: "google/api/nopackage.proto", Content: []byte(`syntax = "proto3";
+option go_package = "example.com/other";
+`)},
+		{Path: "google/api/nogo.proto", Content: []byte(`syntax = "proto3";
+package google.api;
+`)},
+		{Path: "google/api/broken.proto", Content: []byte(`syntax = "proto3"; message {`)},
+	}
+
+	tests := []struct {
+		name       string
+		policy     *config.PolicyConfig
+		license    *file.File
+		violations []string
+	}{
+		{
+			name:       "no policy",
+			violations: []string{},
+		},
+		{
+			name:    "missing license",
+			policy:  &config.PolicyConfig{RequireLicense: true},
+			license: nil,
+			violations: []string{
+				"github.com/googleapis/googleapis: repository has no LICENSE or COPYING file (license_required)",
+			},
+		},
+		{
+			name:       "allowed license",
+			policy:     &config.PolicyConfig{Licenses: []string{"MIT", "apache-2.0"}},
+			license:    apache,
+			violations: []string{},
+		},
+		{
+			name:    "disallowed license",
+			policy:  &config.PolicyConfig{Licenses: []string{"MIT"}},
+			license: apache,
+			violations: []string{
+				"github.com/googleapis/googleapis: license Apache-2.0 is not allowed, allowed are MIT (license_allowed)",
+			},
+		},
+		{
+			name:    "unrecognized license",
+			policy:  &config.PolicyConfig{Licenses: []string{"MIT"}},
+			license: &file.File{Path: "COPYING", Content: []byte("All rights reserved.")},
+			violations: []string{
+				"github.com/googleapis/googleapis: license in COPYING is not recognized, allowed are MIT (license_allowed)",
+			},
+		},
+		{
+			name:   "files",
+			policy: &config.PolicyConfig{MaxFileSize: 100, RequirePackage: true, GoPackage: `^google\.golang\.org/genproto/`},
+			violations: []string{
+				"github.com/googleapis/googleapis: google/api/http.proto: file is 128 bytes, larger than 100 (max_file_size)",
+				"github.com/googleapis/googleapis: google/api/nopackage.proto: file declares no package (package_required)",
+				`github.com/googleapis/googleapis: google/api/nopackage.proto: go_package "example.com/other" does not match "^google\\.golang\\.org/genproto/" (go_package)`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dep := config.Buf3pdDep{Repo: "github.com/googleapis/googleapis", Policy: tt.policy}
+
+			violations, err := Check(dep, files, tt.license)
+			require.NoError(t, err)
+
+			messages := []string{}
+			for _, violation := range violations {
+				messages = append(messages, violation.String())
+			}
+			assert.Equal(t, tt.violations, messages)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Report describes the upstream changes of the dependencies that moved to a different commit
+type Report struct {
+	Deps []*Dep `json:"deps"`
+}
+
+// Dep describes how a dependency changed between its locked commit and the new one
+type Dep struct {
+	Repo    string           `json:"repo"`
+	Path    string           `json:"path"`
+	Ref     string           `json:"ref"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Changes []*source.Change `json:"changes"`
+	// ChangesError explains why Changes could not be listed
+	ChangesError string      `json:"changes_error,omitempty"`
+	Added        []string    `json:"added"`
+	Removed      []string    `json:"removed"`
+	Modified     []string    `json:"modified"`
+	Diffs        []*FileDiff `json:"diffs"`
+}
+
+// FileDiff is the unified diff of a modified vendored file
+type FileDiff struct {
+	Path string `json:"path"`
+	Diff string `json:"diff"`
+}
+
+// NewDep creates the report of a processed dependency, diffing the modified proto files between
+// the previously vendored and the current files. Upstream changes are filled in by the caller.
+func NewDep(result *deps.Result, previous []*file.File, current []*file.File) (*Dep, error) {
+	dep := &Dep{
+		Repo:     result.Repo,
+		Path:     result.Path,
+		Ref:      result.Ref,
+		From:     result.PreviousCommit,
+		To:       result.Commit,
+		Changes:  []*source.Change{},
+		Added:    result.Added,
+		Removed:  result.Removed,
+		Modified: result.Changed,
+		Diffs:    []*FileDiff{},
+	}
+
+	previousByPath := make(map[string][]byte, len(previous))
+	for _, f := range previous {
+		previousByPath[f.Path] = f.Content
+	}
+	currentByPath := make(map[string][]byte, len(current))
+	for _, f := range current {
+		currentByPath[f.Path] = f.Content
+	}
+
+	for _, path := range result.Changed {
+		if !strings.HasSuffix(path, ".proto") {
+			continue
+		}
+		diff, err := UnifiedDiff(path, previousByPath[path], currentByPath[path])
+		if err != nil {
+			return nil, err
+		}
+		dep.Diffs = append(dep.Diffs, &FileDiff{Path: path, Diff: diff})
+	}
+
+	return dep, nil
+}
+
+// UnifiedDiff returns the unified diff between two versions of a file
+func UnifiedDiff(path string, before []byte, after []byte) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+	if err != nil {
+		return "", errors.Errorf("diffing %s: %w", path, err)
+	}
+	return diff, nil
+}
+
+// splitLines splits content into newline-terminated lines. Unlike difflib.SplitLines, it does not
+// add an empty line after a trailing newline.
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// WriteMarkdown writes the report as markdown, suitable for a pull request description
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("## Upstream changes\n\n")
+
+	if len(r.Deps) == 0 {
+		b.WriteString("No dependencies moved to a different commit.\n")
+	}
+
+	for _, dep := range r.Deps {
+		fmt.Fprintf(&b, "### %s (`%s` @ `%s`)\n\n", dep.Repo, dep.Path, dep.Ref)
+		fmt.Fprintf(&b, "`%s` → `%s`\n\n", shortVersion(dep.From), shortVersion(dep.To))
+
+		switch {
+		case dep.ChangesError != "":
+			fmt.Fprintf(&b, "Commits could not be listed: %s\n\n", dep.ChangesError)
+		case len(dep.Changes) == 0:
+			b.WriteString("No commits touch the vendored path.\n\n")
+		default:
+			fmt.Fprintf(&b, "#### Commits (%d)\n\n", len(dep.Changes))
+			for _, change := range dep.Changes {
+				fmt.Fprintf(&b, "- `%s` %s (%s, %s)\n", shortVersion(change.Version), change.Summary, change.Author, shortDate(change.Date))
+			}
+			b.WriteString("\n")
+		}
+
+		if len(dep.Added)+len(dep.Removed)+len(dep.Modified) > 0 {
+			b.WriteString("#### Files\n\n")
+			for _, path := range dep.Added {
+				fmt.Fprintf(&b, "- added `%s`\n", path)
+			}
+			for _, path := range dep.Removed {
+				fmt.Fprintf(&b, "- removed `%s`\n", path)
+			}
+			for _, path := range dep.Modified {
+				fmt.Fprintf(&b, "- modified `%s`\n", path)
+			}
+			b.WriteString("\n")
+		}
+
+		for _, diff := range dep.Diffs {
+			fmt.Fprintf(&b, "<details><summary>Diff of <code>%s</code></summary>\n\n```diff\n%s\n```\n\n</details>\n\n", diff.Path, strings.TrimSuffix(diff.Diff, "\n"))
+		}
+	}
+
+	if _, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n"); err != nil {
+		return errors.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// shortVersion abbreviates commit hashes, leaving other versions unchanged
+func shortVersion(version string) string {
+	if len(version) == 40 && strings.Trim(version, "0123456789abcdef") == "" {
+		return version[:12]
+	}
+	return version
+}
+
+// shortDate keeps the day of an ISO 8601 timestamp
+func shortDate(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
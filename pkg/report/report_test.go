@@ -0,0 +1,70 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/deps"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/source"
+)
+
+func TestReport(t *testing.T) {
+	previous := []*file.File{
+		{Path: "a.proto", Content: []byte("syntax = \"proto3\";\n\nmessage A {}\n")},
+		{Path: "old.proto", Content: []byte("syntax = \"proto3\";\n")},
+	}
+	current := []*file.File{
+		{Path: "a.proto", Content: []byte("syntax = \"proto3\";\n\nmessage A {\n  string name = 1;\n}\n")},
+		{Path: "new.proto", Content: []byte("syntax = \"proto3\";\n")},
+	}
+
+	added, removed, changed := deps.DiffFiles(previous, current)
+	dep, err := NewDep(&deps.Result{
+		Repo:           "github.com/example/repo",
+		Path:           "proto",
+		Ref:            "heads/main",
+		PreviousCommit: "1111111111111111111111111111111111111111",
+		Commit:         "2222222222222222222222222222222222222222",
+		Added:          added,
+		Removed:        removed,
+		Changed:        changed,
+	}, previous, current)
+	require.NoError(t, err)
+	dep.Changes = []*source.Change{
+		{Version: "2222222222222222222222222222222222222222", Author: "Jane", Date: "2025-01-02T03:04:05Z", Summary: "Add name to A"},
+	}
+
+	require.Len(t, dep.Diffs, 1)
+	assert.Equal(t, `--- a/a.proto
++++ b/a.proto
+@@ -1,3 +1,5 @@
+ syntax = "proto3";
+ 
+-message A {}
++message A {
++  string name = 1;
++}
+`, dep.Diffs[0].Diff)
+
+	var buf bytes.Buffer
+	require.NoError(t, (&Report{Deps: []*Dep{dep}}).WriteMarkdown(&buf))
+	assert.Equal(t, "## Upstream changes\n\n"+
+		"### github.com/example/repo (`proto` @ `heads/main`)\n\n"+
+		"`111111111111` → `222222222222`\n\n"+
+		"#### Commits (1)\n\n"+
+		"- `222222222222` Add name to A (Jane, 2025-01-02)\n\n"+
+		"#### Files\n\n"+
+		"- added `new.proto`\n"+
+		"- removed `old.proto`\n"+
+		"- modified `a.proto`\n\n"+
+		"<details><summary>Diff of <code>a.proto</code></summary>\n\n"+
+		"```diff\n"+dep.Diffs[0].Diff+"```\n\n"+
+		"</details>\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, (&Report{}).WriteMarkdown(&buf))
+	assert.Equal(t, "## Upstream changes\n\nNo dependencies moved to a different commit.\n", buf.String())
+}
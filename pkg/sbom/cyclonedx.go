@@ -0,0 +1,152 @@
+package sbom
+
+import (
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/license"
+)
+
+// cycloneDXSpecVersion is the CycloneDX specification version of the written documents
+const cycloneDXSpecVersion = "1.5"
+
+type cdxBOM struct {
+	BOMFormat   string          `json:"bomFormat"`
+	SpecVersion string          `json:"specVersion"`
+	Version     int             `json:"version"`
+	Metadata    cdxMetadata     `json:"metadata"`
+	Components  []*cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []*cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string                  `json:"type"`
+	BOMRef             string                  `json:"bom-ref,omitempty"`
+	Name               string                  `json:"name"`
+	Version            string                  `json:"version,omitempty"`
+	Hashes             []*cdxHash              `json:"hashes,omitempty"`
+	Licenses           []*cdxLicenseChoice     `json:"licenses,omitempty"`
+	PURL               string                  `json:"purl,omitempty"`
+	ExternalReferences []*cdxExternalReference `json:"externalReferences,omitempty"`
+	Properties         []*cdxProperty          `json:"properties,omitempty"`
+	Components         []*cdxComponent         `json:"components,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicenseChoice struct {
+	License cdxLicense `json:"license"`
+}
+
+type cdxLicense struct {
+	ID string `json:"id"`
+}
+
+type cdxExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDX converts the document to a CycloneDX BOM. Serial number and timestamp are left out,
+// since both would change on every run.
+func (d *Document) cycloneDX() *cdxBOM {
+	bom := &cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cycloneDXSpecVersion,
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools:     cdxTools{Components: []*cdxComponent{{Type: "application", Name: d.Tool, Version: d.ToolVersion}}},
+			Component: cdxComponent{Type: "application", Name: d.Name},
+		},
+		Components: []*cdxComponent{},
+	}
+
+	for _, c := range d.Components {
+		ref := bomRef(c)
+		component := &cdxComponent{
+			Type:       "library",
+			BOMRef:     ref,
+			Name:       c.Repo,
+			Version:    c.Commit,
+			PURL:       purl(c),
+			Properties: []*cdxProperty{{Name: "buf3pd:ref", Value: c.Ref}},
+		}
+		if c.isGit() {
+			component.ExternalReferences = []*cdxExternalReference{{Type: "vcs", URL: repoURL(c.Repo)}}
+		}
+		if c.Type != "" {
+			component.Properties = append(component.Properties, &cdxProperty{Name: "buf3pd:type", Value: c.Type})
+		}
+		if c.Path != "" {
+			component.Properties = append(component.Properties, &cdxProperty{Name: "buf3pd:path", Value: c.Path})
+		}
+		// The manifest digest is not a hash of an artifact, so it is recorded as a property
+		if c.SHA256 != "" {
+			component.Properties = append(component.Properties, &cdxProperty{Name: "buf3pd:digest", Value: "sha256:" + c.SHA256})
+		}
+		if c.ModuleDigest != "" {
+			component.Properties = append(component.Properties, &cdxProperty{Name: "buf3pd:module_digest", Value: c.ModuleDigest})
+		}
+		// CycloneDX has no identifiers for a missing or unrecognized license, so those are left out
+		if c.License != "" && c.License != license.None && c.License != license.NoAssertion {
+			component.Licenses = []*cdxLicenseChoice{{License: cdxLicense{ID: c.License}}}
+		}
+		for _, f := range c.Files {
+			fileComponent := &cdxComponent{Type: "file", BOMRef: ref + "#" + f.Path, Name: f.Path}
+			if f.SHA256 != "" {
+				fileComponent.Hashes = []*cdxHash{{Alg: "SHA-256", Content: f.SHA256}}
+			}
+			component.Components = append(component.Components, fileComponent)
+		}
+
+		bom.Components = append(bom.Components, component)
+	}
+
+	return bom
+}
+
+// bomRef identifies a component within the BOM, which may vendor several paths of a repository
+func bomRef(c *Component) string {
+	ref := c.Repo
+	if c.Path != "" && c.Path != "." {
+		ref += "/" + c.Path
+	}
+	if c.Commit != "" {
+		ref += "@" + c.Commit
+	}
+	return ref
+}
+
+// purl returns the package URL of a git component hosted on GitHub, or an empty string otherwise
+func purl(c *Component) string {
+	if !c.isGit() {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(c.Repo, "github.com/")
+	if !ok || strings.Count(rest, "/") != 1 {
+		return ""
+	}
+	purl := "pkg:github/" + strings.ToLower(rest)
+	if c.Commit != "" {
+		purl += "@" + c.Commit
+	}
+	if c.Path != "" && c.Path != "." {
+		purl += "#" + c.Path
+	}
+	return purl
+}
@@ -0,0 +1,139 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+	"github.com/walteh/buf3pd/pkg/source"
+	"gitlab.com/tozd/go/errors"
+)
+
+// SBOM formats
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// Formats lists the supported SBOM formats
+var Formats = []string{FormatCycloneDX, FormatSPDX}
+
+// Document describes the vendored dependencies of a project, built from its lock file
+type Document struct {
+	// Name is the name of the project vendoring the dependencies
+	Name string
+	// Tool is the name and version of the tool creating the document
+	Tool        string
+	ToolVersion string
+	// Created is the RFC 3339 creation time recorded by formats that require one
+	Created    string
+	Components []*Component
+}
+
+// Component is a vendored dependency
+type Component struct {
+	Repo string
+	Path string
+	Ref  string
+	// Type is the dependency's source, git or the type of a source plugin
+	Type   string
+	Commit string
+	// SHA256 is the hex SHA-256 manifest digest of the vendored files, empty for legacy digests
+	SHA256 string
+	// ModuleDigest is the buf b5 digest of the vendored files
+	ModuleDigest string
+	// License is the SPDX identifier of the license as recorded in the lock file, which may be NONE,
+	// NOASSERTION, or empty if the source could not read it
+	License string
+	Files   []*File
+}
+
+// File is a vendored file of a component
+type File struct {
+	Path   string
+	SHA256 string
+}
+
+// NewDocument creates a document from the entries of a lock file, sorted by repo and path so the
+// output does not depend on the order of the config
+func NewDocument(name string, lockFile *lock.File) *Document {
+	doc := &Document{Name: name, Components: []*Component{}}
+
+	for _, dep := range lockFile.Deps {
+		component := &Component{
+			Repo:         dep.Repo,
+			Path:         dep.Path,
+			Ref:          dep.Ref,
+			Type:         dep.Metadata.Type,
+			Commit:       dep.Metadata.Commit,
+			SHA256:       sha256Hex(dep.Digest),
+			ModuleDigest: dep.ModuleDigest,
+			License:      dep.Metadata.License,
+			Files:        []*File{},
+		}
+		for _, digest := range dep.Files {
+			component.Files = append(component.Files, &File{Path: digest.Path, SHA256: sha256Hex(digest.Digest)})
+		}
+		slices.SortFunc(component.Files, func(a, b *File) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+
+		doc.Components = append(doc.Components, component)
+	}
+
+	slices.SortFunc(doc.Components, func(a, b *Component) int {
+		return strings.Compare(a.Repo+"\x00"+a.Path, b.Repo+"\x00"+b.Path)
+	})
+
+	return doc
+}
+
+// Write writes the document in a format
+func (d *Document) Write(w io.Writer, format string) error {
+	var v any
+	switch format {
+	case FormatCycloneDX:
+		v = d.cycloneDX()
+	case FormatSPDX:
+		v = d.spdx()
+	default:
+		return errors.Errorf("unknown SBOM format %q, expected %s", format, strings.Join(Formats, " or "))
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return errors.Errorf("encoding %s SBOM: %w", format, err)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return errors.Errorf("writing SBOM: %w", err)
+	}
+	return nil
+}
+
+// repoURL returns the https URL of a repository given without scheme
+func repoURL(repo string) string {
+	return "https://" + repo
+}
+
+// isGit reports whether a component was vendored from a git repository, the only source whose repo
+// is known to be a repository URL
+func (c *Component) isGit() bool {
+	return c.Type == source.GitType
+}
+
+// sha256Hex returns the hex digest of a buf3pd SHA-256 digest, or an empty string for digests
+// written by older lock files
+func sha256Hex(digest string) string {
+	if !strings.HasPrefix(digest, file.DigestPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(digest, file.DigestPrefix)
+}
@@ -0,0 +1,136 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/lock"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func testLockFile() *lock.File {
+	return &lock.File{
+		Version: lock.VersionV3,
+		Deps: []*lock.Dep{
+			{
+				Repo:         "github.com/googleapis/googleapis",
+				Path:         "google/api",
+				Ref:          "heads/master",
+				Digest:       "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				ModuleDigest: "b5:abcd",
+				Metadata: lock.LockDepMetadata{
+					Commit:      "c9f2cb4e4aa2676f9eaea044d36001a2676ef124",
+					Type:        "git",
+					License:     "Apache-2.0",
+					LicenseFile: "LICENSE",
+				},
+				Files: []*file.Digest{
+					{Path: "http.proto", Digest: "sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+					{Path: "annotations.proto", Digest: "sha256:3333333333333333333333333333333333333333333333333333333333333333"},
+				},
+			},
+			{
+				Repo:   "gitea.internal/protos/common",
+				Ref:    "tags/v1.0.0",
+				Digest: "58b59af8ca3bc462683d24f92187783146187e5876a940d9bfbb777b5db558c2",
+				Metadata: lock.LockDepMetadata{
+					Commit:  "0123456789abcdef0123456789abcdef01234567",
+					Type:    "git",
+					License: "NONE",
+				},
+			},
+			{
+				Repo:   "artifacts.acme.internal/protos/billing",
+				Ref:    "v2.3.1",
+				Digest: "sha256:4444444444444444444444444444444444444444444444444444444444444444",
+				Metadata: lock.LockDepMetadata{
+					Commit:  "v2.3.1",
+					Type:    "artifactory",
+					License: "MIT",
+				},
+				Files: []*file.Digest{
+					{Path: "billing/v1/invoice.proto", Digest: "sha256:5555555555555555555555555555555555555555555555555555555555555555"},
+				},
+			},
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			doc := NewDocument("example", testLockFile())
+			doc.Tool = "buf3pd"
+			doc.ToolVersion = "v1.0.0"
+			doc.Created = "1970-01-01T00:00:00Z"
+
+			var buf bytes.Buffer
+			require.NoError(t, doc.Write(&buf, format))
+
+			goldenPath := filepath.Join("testdata", format+".golden.json")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll("testdata", 0755))
+				require.NoError(t, os.WriteFile(goldenPath, buf.Bytes(), 0644))
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), buf.String())
+		})
+	}
+}
+
+func TestNewDocumentIsDeterministic(t *testing.T) {
+	lockFile := testLockFile()
+	reversed := testLockFile()
+	reversed.Deps[0], reversed.Deps[1] = reversed.Deps[1], reversed.Deps[0]
+
+	for _, format := range Formats {
+		var a, b bytes.Buffer
+		require.NoError(t, NewDocument("example", lockFile).Write(&a, format))
+		require.NoError(t, NewDocument("example", reversed).Write(&b, format))
+		assert.Equal(t, a.String(), b.String(), format)
+	}
+}
+
+func TestWriteNonGitDependency(t *testing.T) {
+	doc := NewDocument("example", testLockFile())
+
+	var cdx bytes.Buffer
+	require.NoError(t, doc.Write(&cdx, FormatCycloneDX))
+	var bom cdxBOM
+	require.NoError(t, json.Unmarshal(cdx.Bytes(), &bom))
+
+	var spdxOut bytes.Buffer
+	require.NoError(t, doc.Write(&spdxOut, FormatSPDX))
+	var spdxDoc spdxDocument
+	require.NoError(t, json.Unmarshal(spdxOut.Bytes(), &spdxDoc))
+
+	for i, component := range bom.Components {
+		pkg := spdxDoc.Packages[i]
+		require.Equal(t, component.Name, pkg.Name)
+
+		// Only git dependencies are described by a repository URL
+		if component.Name == "artifacts.acme.internal/protos/billing" {
+			assert.Empty(t, component.ExternalReferences)
+			assert.Empty(t, component.PURL)
+			assert.Equal(t, "NOASSERTION", pkg.DownloadLocation)
+			continue
+		}
+		assert.Equal(t, []*cdxExternalReference{{Type: "vcs", URL: "https://" + component.Name}}, component.ExternalReferences)
+		assert.Contains(t, pkg.DownloadLocation, "git+https://"+component.Name)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	err := NewDocument("example", testLockFile()).Write(&bytes.Buffer{}, "swid")
+	assert.EqualError(t, err, `unknown SBOM format "swid", expected cyclonedx or spdx`)
+}
@@ -0,0 +1,154 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/walteh/buf3pd/pkg/license"
+)
+
+// spdxVersion is the SPDX specification version of the written documents
+const spdxVersion = "SPDX-2.3"
+
+type spdxDocument struct {
+	SPDXVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SPDXID            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo    `json:"creationInfo"`
+	Packages          []*spdxPackage      `json:"packages"`
+	Files             []*spdxFile         `json:"files"`
+	Relationships     []*spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string             `json:"SPDXID"`
+	Name             string             `json:"name"`
+	VersionInfo      string             `json:"versionInfo,omitempty"`
+	DownloadLocation string             `json:"downloadLocation"`
+	FilesAnalyzed    bool               `json:"filesAnalyzed"`
+	LicenseConcluded string             `json:"licenseConcluded"`
+	LicenseDeclared  string             `json:"licenseDeclared"`
+	CopyrightText    string             `json:"copyrightText"`
+	ExternalRefs     []*spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string             `json:"comment,omitempty"`
+}
+
+type spdxFile struct {
+	SPDXID           string          `json:"SPDXID"`
+	FileName         string          `json:"fileName"`
+	Checksums        []*spdxChecksum `json:"checksums"`
+	LicenseConcluded string          `json:"licenseConcluded"`
+	CopyrightText    string          `json:"copyrightText"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxIDInvalid matches the characters not allowed in SPDX identifiers
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdx converts the document to an SPDX document. The namespace is derived from the components, so
+// it only changes with them.
+func (d *Document) spdx() *spdxDocument {
+	doc := &spdxDocument{
+		SPDXVersion: spdxVersion,
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        d.Name,
+		CreationInfo: spdxCreationInfo{
+			Created:  d.Created,
+			Creators: []string{fmt.Sprintf("Tool: %s-%s", d.Tool, d.ToolVersion)},
+		},
+		Packages:      []*spdxPackage{},
+		Files:         []*spdxFile{},
+		Relationships: []*spdxRelationship{},
+	}
+
+	hash := sha256.New()
+	for _, c := range d.Components {
+		name := spdxIDInvalid.ReplaceAllString(strings.TrimSuffix(c.Repo+"-"+c.Path, "-"), "-")
+		id := "SPDXRef-Package-" + name
+		fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00", c.Repo, c.Path, c.Commit, c.SHA256)
+
+		licenseID := c.License
+		if licenseID == "" {
+			licenseID = license.NoAssertion
+		}
+
+		// Only git repos have a download location SPDX can express
+		downloadLocation := license.NoAssertion
+		if c.isGit() {
+			downloadLocation = "git+" + repoURL(c.Repo)
+			if c.Commit != "" {
+				downloadLocation += "@" + c.Commit
+			}
+			if c.Path != "" && c.Path != "." {
+				downloadLocation += "#" + c.Path
+			}
+		}
+
+		pkg := &spdxPackage{
+			SPDXID:           id,
+			Name:             c.Repo,
+			VersionInfo:      c.Commit,
+			DownloadLocation: downloadLocation,
+			LicenseConcluded: licenseID,
+			LicenseDeclared:  licenseID,
+			CopyrightText:    license.NoAssertion,
+			Comment:          fmt.Sprintf("Vendored by buf3pd from ref %s.", c.Ref),
+		}
+		// The manifest digest is not a checksum of a package archive, so it is only described
+		if c.SHA256 != "" {
+			pkg.Comment = fmt.Sprintf("Vendored by buf3pd from ref %s, with manifest digest sha256:%s.", c.Ref, c.SHA256)
+		}
+		if p := purl(c); p != "" {
+			pkg.ExternalRefs = []*spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: p}}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, &spdxRelationship{SPDXElementID: doc.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: id})
+
+		for i, f := range c.Files {
+			fileID := fmt.Sprintf("SPDXRef-File-%s-%d", name, i)
+			entry := &spdxFile{
+				SPDXID:           fileID,
+				FileName:         "./" + f.Path,
+				Checksums:        []*spdxChecksum{},
+				LicenseConcluded: licenseID,
+				CopyrightText:    license.NoAssertion,
+			}
+			if f.SHA256 != "" {
+				entry.Checksums = append(entry.Checksums, &spdxChecksum{Algorithm: "SHA256", ChecksumValue: f.SHA256})
+			}
+			doc.Files = append(doc.Files, entry)
+			doc.Relationships = append(doc.Relationships, &spdxRelationship{SPDXElementID: id, RelationshipType: "CONTAINS", RelatedSPDXElement: fileID})
+		}
+	}
+
+	doc.DocumentNamespace = fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s", spdxIDInvalid.ReplaceAllString(d.Name, "-"), hex.EncodeToString(hash.Sum(nil))[:16])
+
+	return doc
+}
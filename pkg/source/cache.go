@@ -0,0 +1,174 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+// Cache shares resolved pins, fetched files and license files between sources, so a dependency
+// declared identically by several projects is only resolved and fetched once per run
+type Cache struct {
+	mu    sync.Mutex
+	pins  map[string]*Pin
+	files map[string][]*file.File
+	// licenses holds the license file of every repository and version read, nil if it has none
+	licenses map[string]*file.File
+	fetches  int
+	reused   int
+}
+
+// NewCache creates a new, empty Cache
+func NewCache() *Cache {
+	return &Cache{
+		pins:     map[string]*Pin{},
+		files:    map[string][]*file.File{},
+		licenses: map[string]*file.File{},
+	}
+}
+
+// Stats returns the number of fetches made through the cache and the number served from it
+func (c *Cache) Stats() (fetches int, reused int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fetches, c.reused
+}
+
+// Wrap returns a Source sharing the results of src through the cache
+func (c *Cache) Wrap(src Source) Source {
+	if cached, ok := As[*cachingSource](src); ok && cached.cache == c {
+		return src
+	}
+	cached := &cachingSource{
+		Source: src,
+		cache:  c,
+	}
+	// Only sources reading licenses get a License method, so As keeps reporting what src supports
+	if _, ok := As[LicenseSource](src); ok {
+		return &cachingLicenseSource{cached}
+	}
+	return cached
+}
+
+// cacheKey identifies a dependency by the fields that determine what a source returns for it
+func cacheKey(dep config.Buf3pdDep) string {
+	return strings.Join([]string{dep.Type, dep.Repo, dep.Path, dep.Ref, strings.Join(dep.Filter, ",")}, "\x00")
+}
+
+// cachingSource is a Source whose results are shared through a Cache
+type cachingSource struct {
+	Source
+	cache *Cache
+}
+
+// Unwrap returns the wrapped source
+func (s *cachingSource) Unwrap() Source {
+	return s.Source
+}
+
+// Resolve resolves the dependency once, returning the same pin for identical dependencies
+func (s *cachingSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*Pin, error) {
+	key := cacheKey(dep)
+
+	s.cache.mu.Lock()
+	pin, ok := s.cache.pins[key]
+	s.cache.mu.Unlock()
+	if ok {
+		return pin, nil
+	}
+
+	pin, err := s.Source.Resolve(ctx, dep)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.mu.Lock()
+	s.cache.pins[key] = pin
+	s.cache.mu.Unlock()
+
+	return pin, nil
+}
+
+// Fetch fetches the pinned dependency once, returning a copy of the same files for identical dependencies
+func (s *cachingSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *Pin) ([]*file.File, error) {
+	key := cacheKey(dep) + "\x00" + pin.Version
+
+	s.cache.mu.Lock()
+	files, ok := s.cache.files[key]
+	if ok {
+		s.cache.reused++
+	}
+	s.cache.mu.Unlock()
+	if ok {
+		zerolog.Ctx(ctx).Info().Str("repo", dep.Repo).Str("version", pin.Version).Msg("reusing fetched dependency")
+		return copyFiles(files), nil
+	}
+
+	files, err := s.Source.Fetch(ctx, dep, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.mu.Lock()
+	s.cache.files[key] = copyFiles(files)
+	s.cache.fetches++
+	s.cache.mu.Unlock()
+
+	return files, nil
+}
+
+// cachingLicenseSource is a cachingSource also sharing the license files its source reads
+type cachingLicenseSource struct {
+	*cachingSource
+}
+
+// Unwrap returns the caching source
+func (s *cachingLicenseSource) Unwrap() Source {
+	return s.cachingSource
+}
+
+// License reads the license file of a repository version once. It does not depend on the path or
+// filter of the dependency, since the license file is at the repository root.
+func (s *cachingLicenseSource) License(ctx context.Context, dep config.Buf3pdDep, pin *Pin) (*file.File, error) {
+	key := strings.Join([]string{dep.Type, dep.Repo, pin.Version}, "\x00")
+
+	s.cache.mu.Lock()
+	licenseFile, ok := s.cache.licenses[key]
+	s.cache.mu.Unlock()
+	if ok {
+		return copyFile(licenseFile), nil
+	}
+
+	licenser, _ := As[LicenseSource](s.Source)
+	licenseFile, err := licenser.License(ctx, dep, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.mu.Lock()
+	s.cache.licenses[key] = copyFile(licenseFile)
+	s.cache.mu.Unlock()
+
+	return licenseFile, nil
+}
+
+// copyFile copies a file, which may be nil
+func copyFile(f *file.File) *file.File {
+	if f == nil {
+		return nil
+	}
+	return &file.File{Path: f.Path, Content: append([]byte(nil), f.Content...)}
+}
+
+// copyFiles copies a list of files, so callers never share the files held by the cache
+func copyFiles(files []*file.File) []*file.File {
+	copied := make([]*file.File, 0, len(files))
+	for _, f := range files {
+		copied = append(copied, copyFile(f))
+	}
+	return copied
+}
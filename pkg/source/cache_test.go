@@ -0,0 +1,84 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+// countingSource is a Source that counts how often it is called
+type countingSource struct {
+	resolves int
+	fetches  int
+}
+
+func (s *countingSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*Pin, error) {
+	s.resolves++
+	return &Pin{Version: "v1"}, nil
+}
+
+func (s *countingSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *Pin) ([]*file.File, error) {
+	s.fetches++
+	return []*file.File{{Path: "a.proto", Content: []byte("syntax = \"proto3\";")}}, nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCache()
+	upstream := &countingSource{}
+
+	// Registries of different projects share the cache
+	first := NewRegistry()
+	first.Register("counting", upstream)
+	first.UseCache(cache)
+	second := NewRegistry()
+	second.UseCache(cache)
+	second.Register("counting", upstream)
+
+	dep := config.Buf3pdDep{Type: "counting", Repo: "example.com/repo", Ref: "main"}
+	other := config.Buf3pdDep{Type: "counting", Repo: "example.com/repo", Ref: "main", Filter: []string{"a/**"}}
+
+	for _, registry := range []*Registry{first, second} {
+		src, ok := registry.Lookup("counting")
+		require.True(t, ok)
+
+		pin, err := src.Resolve(ctx, dep)
+		require.NoError(t, err)
+		files, err := src.Fetch(ctx, dep, pin)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		// Callers may modify the files they get without affecting the cache
+		files[0].Content = []byte("modified")
+	}
+
+	src, _ := second.Lookup("counting")
+	files, err := src.Fetch(ctx, dep, &Pin{Version: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, "syntax = \"proto3\";", string(files[0].Content))
+
+	_, err = src.Fetch(ctx, other, &Pin{Version: "v1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, upstream.resolves)
+	assert.Equal(t, 2, upstream.fetches)
+
+	fetches, reused := cache.Stats()
+	assert.Equal(t, 2, fetches)
+	assert.Equal(t, 2, reused)
+}
+
+func TestCacheOnlyAddsLicenseToLicenseSources(t *testing.T) {
+	cache := NewCache()
+
+	// A source that cannot read licenses does not gain the ability by being cached
+	_, ok := As[LicenseSource](cache.Wrap(&countingSource{}))
+	assert.False(t, ok)
+
+	_, ok = As[LicenseSource](cache.Wrap(NewGitSource(file.NewManager(), nil)))
+	assert.True(t, ok)
+}
@@ -0,0 +1,207 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/git"
+	"github.com/walteh/buf3pd/pkg/license"
+	"gitlab.com/tozd/go/errors"
+)
+
+// GitType is the dependency type handled by GitSource
+const GitType = "git"
+
+// GitSource implements the Source interface for git repositories
+type GitSource struct {
+	fileHandler file.Handler
+	gitHandler  git.Handler
+
+	// licenses holds the license files read while fetching, by repo and commit
+	mu       sync.Mutex
+	licenses map[string]*file.File
+}
+
+// NewGitSource creates a new GitSource
+func NewGitSource(fileHandler file.Handler, gitHandler git.Handler) *GitSource {
+	return &GitSource{
+		fileHandler: fileHandler,
+		gitHandler:  gitHandler,
+		licenses:    map[string]*file.File{},
+	}
+}
+
+// Resolve resolves the dependency reference to a commit hash
+func (s *GitSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*Pin, error) {
+	commit, err := s.gitHandler.ResolveRef(dep.Repo, dep.Ref)
+	if err != nil {
+		return nil, errors.Errorf("resolving reference: %w", err)
+	}
+
+	zerolog.Ctx(ctx).Info().Str("repo", dep.Repo).Str("ref", dep.Ref).Str("commit", commit).Msg("resolved git reference")
+
+	return &Pin{Version: commit}, nil
+}
+
+// Fetch checks out the pinned commit and returns the proto files matching the dependency filters
+func (s *GitSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *Pin) ([]*file.File, error) {
+	tempDir, err := s.checkout(dep, pin)
+	if err != nil {
+		return nil, err
+	}
+	defer git.CleanupTempDir(tempDir)
+
+	// Keep the license file, so reading the license of the fetched commit needs no other clone
+	if _, err := s.readLicense(dep, pin, tempDir); err != nil {
+		return nil, err
+	}
+
+	root := filepath.Join(tempDir, dep.Path)
+
+	paths, err := s.fileHandler.FindProtoFiles(root, dep.Filter)
+	if err != nil {
+		return nil, errors.Errorf("finding proto files: %w", err)
+	}
+
+	files := make([]*file.File, 0, len(paths))
+	for _, path := range paths {
+		zerolog.Ctx(ctx).Info().Str("file", path).Str("path", root).Msg("adding file")
+
+		content, err := s.fileHandler.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			return nil, errors.Errorf("reading file: %w", err)
+		}
+
+		files = append(files, &file.File{
+			Path:    path,
+			Content: content,
+		})
+	}
+
+	return files, nil
+}
+
+// License returns the license file at the root of the repository at the pinned commit. The license
+// of a commit fetched before is read from its checkout, so only dependencies that were not fetched,
+// such as up to date ones locked before licenses were recorded, are cloned again.
+func (s *GitSource) License(ctx context.Context, dep config.Buf3pdDep, pin *Pin) (*file.File, error) {
+	s.mu.Lock()
+	licenseFile, ok := s.licenses[dep.Repo+"@"+pin.Version]
+	s.mu.Unlock()
+	if ok {
+		return licenseFile, nil
+	}
+
+	tempDir, err := s.checkout(dep, pin)
+	if err != nil {
+		return nil, err
+	}
+	defer git.CleanupTempDir(tempDir)
+
+	return s.readLicense(dep, pin, tempDir)
+}
+
+// checkout clones the repository into a temporary directory and checks out the pinned commit. The
+// caller removes the directory.
+func (s *GitSource) checkout(dep config.Buf3pdDep, pin *Pin) (string, error) {
+	tempDir, err := git.CreateTempDir()
+	if err != nil {
+		return "", errors.Errorf("creating temp directory: %w", err)
+	}
+
+	// Clone the repository
+	if err := s.gitHandler.Clone(dep.Repo, tempDir); err != nil {
+		git.CleanupTempDir(tempDir)
+		return "", errors.Errorf("cloning repository: %w", err)
+	}
+
+	// Fetch the pinned commit, which may not be part of the shallow clone
+	if err := s.gitHandler.FetchCommit(tempDir, pin.Version); err != nil {
+		git.CleanupTempDir(tempDir)
+		return "", errors.Errorf("fetching commit: %w", err)
+	}
+
+	// Checkout the pinned commit
+	if err := s.gitHandler.Checkout(tempDir, pin.Version); err != nil {
+		git.CleanupTempDir(tempDir)
+		return "", errors.Errorf("checking out commit: %w", err)
+	}
+
+	return tempDir, nil
+}
+
+// readLicense reads the license file of a checkout of the pinned commit, keeping it for License
+func (s *GitSource) readLicense(dep config.Buf3pdDep, pin *Pin, tempDir string) (*file.File, error) {
+	licenseFile, err := readLicenseFile(tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.licenses[dep.Repo+"@"+pin.Version] = licenseFile
+	s.mu.Unlock()
+
+	return licenseFile, nil
+}
+
+// readLicenseFile reads the first license file at the root of a checkout, in name order, returning
+// nil if there is none
+func readLicenseFile(root string) (*file.File, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, errors.Errorf("reading repository root: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !license.IsLicenseFile(entry.Name()) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, errors.Errorf("reading license file: %w", err)
+		}
+		return &file.File{Path: entry.Name(), Content: content}, nil
+	}
+
+	return nil, nil
+}
+
+// Changes lists the commits between two pins, limited to those touching the dependency path
+func (s *GitSource) Changes(ctx context.Context, dep config.Buf3pdDep, from *Pin, to *Pin) ([]*Change, error) {
+	tempDir, err := git.CreateTempDir()
+	if err != nil {
+		return nil, errors.Errorf("creating temp directory: %w", err)
+	}
+	defer git.CleanupTempDir(tempDir)
+
+	if err := s.gitHandler.CloneHistory(dep.Repo, tempDir); err != nil {
+		return nil, errors.Errorf("cloning repository history: %w", err)
+	}
+
+	var paths []string
+	if dep.Path != "" && dep.Path != "." {
+		paths = append(paths, dep.Path)
+	}
+
+	commits, err := s.gitHandler.Log(tempDir, from.Version, to.Version, paths...)
+	if err != nil {
+		return nil, errors.Errorf("listing commits: %w", err)
+	}
+
+	changes := make([]*Change, 0, len(commits))
+	for _, commit := range commits {
+		changes = append(changes, &Change{
+			Version: commit.Hash,
+			Author:  commit.Author,
+			Date:    commit.Date,
+			Summary: commit.Subject,
+		})
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"github.com/walteh/buf3pd/pkg/git"
+)
+
+// gitRepo creates an empty repository, returning its file URL and a function running git in it
+func gitRepo(t *testing.T) (string, func(args ...string) string) {
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Jane", "GIT_AUTHOR_EMAIL=jane@example.com", "GIT_COMMITTER_NAME=Jane", "GIT_COMMITTER_EMAIL=jane@example.com")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+	run("init", "-q", "-b", "main")
+	return "file://" + repo, run
+}
+
+func TestGitSourceResolve(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	url, run := gitRepo(t)
+	run("commit", "-q", "--allow-empty", "-m", "Release one")
+	release := run("rev-parse", "HEAD")
+	run("tag", "-a", "v1", "-m", "v1")
+	run("commit", "-q", "--allow-empty", "-m", "Release two")
+	other := run("rev-parse", "HEAD")
+	// Tags whose names end like the references, which ls-remote also lists
+	run("tag", "-a", "release/v1", "-m", "release/v1")
+	run("tag", "-a", "heads/main", "-m", "heads/main")
+	run("commit", "-q", "--allow-empty", "-m", "Main")
+	main := run("rev-parse", "HEAD")
+
+	src := NewGitSource(file.NewManager(), git.NewManager())
+	resolve := func(ref string) string {
+		pin, err := src.Resolve(ctx, config.Buf3pdDep{Type: GitType, Repo: url, Ref: ref})
+		require.NoError(t, err)
+		return pin.Version
+	}
+
+	// An annotated tag resolves to the commit of its peeled line, not another tag's
+	assert.Equal(t, release, resolve("v1"))
+	assert.Equal(t, release, resolve("tags/v1"))
+	assert.Equal(t, other, resolve("tags/release/v1"))
+
+	// A branch resolves to its head, not to a tag with the same suffix
+	assert.Equal(t, main, resolve("heads/main"))
+	assert.Equal(t, main, resolve("main"))
+
+	// A commit hash is used as is
+	assert.Equal(t, release, resolve(release))
+
+	_, err := src.Resolve(ctx, config.Buf3pdDep{Type: GitType, Repo: url, Ref: "heads/missing"})
+	assert.ErrorContains(t, err, `reference "heads/missing" not found`)
+}
+
+// countingHandler counts the clones made through a git handler
+type countingHandler struct {
+	git.Handler
+	clones int
+}
+
+func (h *countingHandler) Clone(repo string, path string) error {
+	h.clones++
+	return h.Handler.Clone(repo, path)
+}
+
+func TestGitSourceLicense(t *testing.T) {
+	ctx := zerolog.New(zerolog.NewTestWriter(t)).WithContext(context.Background())
+
+	url, run := gitRepo(t)
+	dir := strings.TrimPrefix(url, "file://")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "proto/acme/v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "proto/acme/v1/acme.proto"), []byte(`syntax = "proto3";`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("SPDX-License-Identifier: MIT\n"), 0644))
+	run("add", "-A")
+	run("commit", "-q", "-m", "Initial protos")
+
+	handler := &countingHandler{Handler: git.NewManager()}
+	cache := NewCache()
+	first := NewRegistry()
+	first.UseCache(cache)
+	first.Register(GitType, NewGitSource(file.NewManager(), handler))
+
+	dep := config.Buf3pdDep{Type: GitType, Repo: url, Path: "proto", Ref: "heads/main"}
+	src, ok := first.Lookup(GitType)
+	require.True(t, ok)
+	pin, err := src.Resolve(ctx, dep)
+	require.NoError(t, err)
+	files, err := src.Fetch(ctx, dep, pin)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// The license is read from the checkout of the fetch
+	licenser, ok := As[LicenseSource](src)
+	require.True(t, ok)
+	licenseFile, err := licenser.License(ctx, dep, pin)
+	require.NoError(t, err)
+	require.NotNil(t, licenseFile)
+	assert.Equal(t, "LICENSE", licenseFile.Path)
+	assert.Equal(t, 1, handler.clones)
+
+	// Another project sharing the cache gets the fetched files and license without cloning
+	second := NewRegistry()
+	second.UseCache(cache)
+	second.Register(GitType, NewGitSource(file.NewManager(), handler))
+	src, _ = second.Lookup(GitType)
+	_, err = src.Fetch(ctx, dep, pin)
+	require.NoError(t, err)
+	licenser, _ = As[LicenseSource](src)
+	licenseFile, err = licenser.License(ctx, dep, pin)
+	require.NoError(t, err)
+	assert.Equal(t, "SPDX-License-Identifier: MIT\n", string(licenseFile.Content))
+	assert.Equal(t, 1, handler.clones)
+}
@@ -0,0 +1,142 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+	"gitlab.com/tozd/go/errors"
+)
+
+// PluginPrefix is the executable name prefix used to discover external sources on PATH
+const PluginPrefix = "buf3pd-source-"
+
+// PluginProtocolVersion is the version of the request/response protocol spoken with plugins
+const PluginProtocolVersion = "v1"
+
+// Plugin methods
+const (
+	PluginMethodResolve = "resolve"
+	PluginMethodFetch   = "fetch"
+)
+
+// reservedMetadataKeys are the lock file metadata fields written by buf3pd itself, which the
+// metadata of a plugin's pin is stored alongside
+var reservedMetadataKeys = []string{"commit", "type", "license", "license_file"}
+
+// PluginRequest is written as JSON to a plugin's stdin
+type PluginRequest struct {
+	Version string           `json:"version"`
+	Method  string           `json:"method"`
+	Dep     config.Buf3pdDep `json:"dep"`
+	Pin     *Pin             `json:"pin,omitempty"`
+}
+
+// PluginResponse is read as JSON from a plugin's stdout
+type PluginResponse struct {
+	Pin   *Pin         `json:"pin,omitempty"`
+	Files []*file.File `json:"files,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// PluginSource implements the Source interface by invoking an external executable
+type PluginSource struct {
+	path string
+}
+
+// NewPluginSource creates a new PluginSource for the executable at path
+func NewPluginSource(path string) *PluginSource {
+	return &PluginSource{
+		path: path,
+	}
+}
+
+// FindPlugin looks for a buf3pd-source-<type> executable on PATH
+func FindPlugin(typ string) (*PluginSource, bool) {
+	path, err := exec.LookPath(PluginPrefix + typ)
+	if err != nil {
+		return nil, false
+	}
+	return NewPluginSource(path), true
+}
+
+// Resolve asks the plugin to resolve the dependency reference to a pinned version
+func (s *PluginSource) Resolve(ctx context.Context, dep config.Buf3pdDep) (*Pin, error) {
+	resp, err := s.call(ctx, &PluginRequest{
+		Method: PluginMethodResolve,
+		Dep:    dep,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Pin == nil || resp.Pin.Version == "" {
+		return nil, errors.Errorf("plugin %s returned no pinned version", s.path)
+	}
+	for _, key := range reservedMetadataKeys {
+		if _, ok := resp.Pin.Metadata[key]; ok {
+			return nil, errors.Errorf("plugin %s returned reserved metadata key %q", s.path, key)
+		}
+	}
+
+	return resp.Pin, nil
+}
+
+// Fetch asks the plugin for the files of the pinned dependency
+func (s *PluginSource) Fetch(ctx context.Context, dep config.Buf3pdDep, pin *Pin) ([]*file.File, error) {
+	resp, err := s.call(ctx, &PluginRequest{
+		Method: PluginMethodFetch,
+		Dep:    dep,
+		Pin:    pin,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Plugin output is untrusted, so every file must stay inside the vendored directory
+	for _, f := range resp.Files {
+		if !filepath.IsLocal(f.Path) {
+			return nil, errors.Errorf("plugin %s returned file path %q outside the dependency directory", s.path, f.Path)
+		}
+	}
+
+	return resp.Files, nil
+}
+
+// call runs the plugin with a single request and decodes its response
+func (s *PluginSource) call(ctx context.Context, req *PluginRequest) (*PluginResponse, error) {
+	req.Version = PluginProtocolVersion
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Errorf("marshalling plugin request: %w", err)
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("plugin", s.path).Str("method", req.Method).Msg("invoking source plugin")
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("running plugin %s: %w", s.path, err)
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, errors.Errorf("unmarshalling plugin response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return nil, errors.Errorf("plugin %s: %s", s.path, resp.Error)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walteh/buf3pd/pkg/config"
+)
+
+const fakePlugin = `#!/bin/sh
+input=$(cat)
+case "$input" in
+*'"method":"resolve"'*)
+	echo '{"pin":{"version":"v1.2.3","metadata":{"artifact":"example-1.2.3.tar"}}}'
+	;;
+*'"method":"fetch"'*)
+	echo '{"files":[{"path":"example/v1/example.proto","content":"c3ludGF4ID0gInByb3RvMyI7"}]}'
+	;;
+*)
+	echo '{"error":"unknown method"}'
+	;;
+esac
+`
+
+func TestPluginSource(t *testing.T) {
+	// Setup test context
+	ctx := context.Background()
+	logger := zerolog.New(zerolog.NewTestWriter(t)).With().Timestamp().Logger()
+	ctx = logger.WithContext(ctx)
+
+	// Install a fake plugin on PATH
+	installPlugin(t, "fake", fakePlugin)
+
+	// Unregistered types are discovered on PATH
+	registry := NewRegistry()
+	src, ok := registry.Lookup("fake")
+	require.True(t, ok)
+	assert.Equal(t, []string{"fake"}, registry.Types())
+
+	_, ok = registry.Lookup("missing")
+	assert.False(t, ok)
+
+	dep := config.Buf3pdDep{Type: "fake", Repo: "artifacts.example.com/example", Ref: "latest"}
+
+	pin, err := src.Resolve(ctx, dep)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", pin.Version)
+	assert.Equal(t, "example-1.2.3.tar", pin.Metadata["artifact"])
+
+	files, err := src.Fetch(ctx, dep, pin)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "example/v1/example.proto", files[0].Path)
+	assert.Equal(t, `syntax = "proto3";`, string(files[0].Content))
+}
+
+// installPlugin installs a plugin script for a dependency type on PATH
+func installPlugin(t *testing.T, typ string, script string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, PluginPrefix+typ), []byte(script), 0755)
+	require.NoError(t, err)
+	t.Setenv("PATH", tempDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+const reservedMetadataPlugin = `#!/bin/sh
+echo '{"pin":{"version":"v1.2.3","metadata":{"commit":"0000000"}}}'
+`
+
+func TestPluginSourceRejectsReservedMetadata(t *testing.T) {
+	ctx := context.Background()
+	installPlugin(t, "reserved", reservedMetadataPlugin)
+
+	src, ok := FindPlugin("reserved")
+	require.True(t, ok)
+
+	// Metadata keys written by buf3pd itself would clash with the lock file's own fields
+	_, err := src.Resolve(ctx, config.Buf3pdDep{Type: "reserved", Repo: "artifacts.example.com/example", Ref: "latest"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `returned reserved metadata key "commit"`)
+}
+
+const escapingPlugin = `#!/bin/sh
+echo '{"files":[{"path":"../../escape.proto","content":""}]}'
+`
+
+func TestPluginSourceRejectsPathsOutsideDir(t *testing.T) {
+	ctx := context.Background()
+	installPlugin(t, "escaping", escapingPlugin)
+
+	src, ok := FindPlugin("escaping")
+	require.True(t, ok)
+
+	// Files must never be written outside the dependency directory
+	dep := config.Buf3pdDep{Type: "escaping", Repo: "artifacts.example.com/example", Ref: "latest"}
+	_, err := src.Fetch(ctx, dep, &Pin{Version: "v1.2.3"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `returned file path "../../escape.proto" outside the dependency directory`)
+}
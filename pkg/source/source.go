@@ -0,0 +1,126 @@
+package source
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/walteh/buf3pd/pkg/config"
+	"github.com/walteh/buf3pd/pkg/file"
+)
+
+// Pin represents the exact version a source resolved a dependency reference to
+type Pin struct {
+	Version  string            `json:"version"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Source provides an interface for resolving and fetching dependencies of a single type
+type Source interface {
+	Resolve(ctx context.Context, dep config.Buf3pdDep) (*Pin, error)
+	Fetch(ctx context.Context, dep config.Buf3pdDep, pin *Pin) ([]*file.File, error)
+}
+
+// Change is an upstream change between two pins of a dependency, such as a git commit
+type Change struct {
+	Version string `json:"version"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Summary string `json:"summary"`
+}
+
+// ChangeLister is implemented by sources that can list the upstream changes between two pins
+type ChangeLister interface {
+	Changes(ctx context.Context, dep config.Buf3pdDep, from *Pin, to *Pin) ([]*Change, error)
+}
+
+// LicenseSource is implemented by sources that can read the license file at the root of a pinned
+// dependency's repository, which usually lies outside the vendored path
+type LicenseSource interface {
+	// License returns the license file, or nil if the repository has none
+	License(ctx context.Context, dep config.Buf3pdDep, pin *Pin) (*file.File, error)
+}
+
+// As returns the implementation of an optional interface T of a source, such as ChangeLister or
+// LicenseSource, looking through sources that wrap another one
+func As[T any](src Source) (T, bool) {
+	for {
+		if impl, ok := src.(T); ok {
+			return impl, true
+		}
+		wrapper, ok := src.(interface{ Unwrap() Source })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		src = wrapper.Unwrap()
+	}
+}
+
+// Registry maps dependency types to the sources that handle them
+type Registry struct {
+	mu      sync.RWMutex
+	sources map[string]Source
+	cache   *Cache
+}
+
+// NewRegistry creates a new, empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		sources: map[string]Source{},
+	}
+}
+
+// Register registers a source for a dependency type, replacing any existing one
+func (r *Registry) Register(typ string, src Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache != nil {
+		src = r.cache.Wrap(src)
+	}
+	r.sources[typ] = src
+}
+
+// UseCache shares the results of every source, registered now or later, through cache
+func (r *Registry) UseCache(cache *Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = cache
+	for typ, src := range r.sources {
+		r.sources[typ] = cache.Wrap(src)
+	}
+}
+
+// Lookup returns the source registered for a dependency type, falling back to a
+// buf3pd-source-<type> plugin on PATH when no compiled-in source is registered
+func (r *Registry) Lookup(typ string) (Source, bool) {
+	r.mu.RLock()
+	src, ok := r.sources[typ]
+	r.mu.RUnlock()
+	if ok {
+		return src, true
+	}
+
+	plugin, ok := FindPlugin(typ)
+	if !ok {
+		return nil, false
+	}
+
+	r.Register(typ, plugin)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sources[typ], true
+}
+
+// Types returns the sorted list of registered dependency types
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.sources))
+	for typ := range r.sources {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	return types
+}